@@ -2,11 +2,15 @@ package composer
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,12 +21,13 @@ import (
 	"github.com/paketo-buildpacks/packit/v2/pexec"
 	"github.com/paketo-buildpacks/packit/v2/sbom"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
 )
 
 // DetermineComposerInstallOptions defines the interface to get options for `composer install`
 //go:generate faux --interface DetermineComposerInstallOptions --output fakes/determine_composer_install_options.go
 type DetermineComposerInstallOptions interface {
-	Determine() []string
+	Determine(planMetadata ComposerPackagesPlanMetadata) []string
 }
 
 // Executable just provides a fake for pexec.Executable for testing
@@ -43,6 +48,61 @@ type Calculator interface {
 	Sum(paths ...string) (string, error)
 }
 
+// BindingResolver defines the interface for resolving service bindings
+//go:generate faux --interface BindingResolver --output fakes/binding_resolver.go
+type BindingResolver interface {
+	Resolve(typ, provider, platformDir string) ([]servicebindings.Binding, error)
+}
+
+// composerSettings are shared by every Composer invocation
+type composerSettings struct {
+	// env is added to the environment of the build
+	env []string
+
+	// homeConfig is written as config.json into COMPOSER_HOME, unless it is nil
+	homeConfig map[string]interface{}
+
+	// mirrors rewrite the dist URLs of locked packages
+	mirrors []ComposerMirror
+
+	// dependencyMappings replace the downloads of locked packages with local archives, when not nil
+	dependencyMappings ComposerDependencyMappings
+
+	// offline is set when Composer must not use the network at all
+	offline bool
+
+	// hardened restricts the plugins and scripts that run during the install, when not nil
+	hardened *ComposerHardenedPolicy
+
+	// hermetic is set when Composer only receives the variables of the build environment on envAllowlist
+	hermetic     bool
+	envAllowlist []string
+}
+
+// rewritesDistURLs returns whether Composer must install from a copy of composer.lock with rewritten dist URLs
+func (s composerSettings) rewritesDistURLs() bool {
+	return len(s.mirrors) > 0 || s.dependencyMappings != nil
+}
+
+// sourcesChecksum returns a checksum of the mirrors, the Composer config and the dependency mappings, which
+// decide where packages are downloaded from. It is empty when packages come from the default repositories.
+func (s composerSettings) sourcesChecksum() (string, error) {
+	if len(s.mirrors) == 0 && s.homeConfig == nil && s.dependencyMappings == nil {
+		return "", nil
+	}
+
+	content, err := json.Marshal(struct {
+		Mirrors            []ComposerMirror           `json:"mirrors"`
+		HomeConfig         map[string]interface{}     `json:"homeConfig"`
+		DependencyMappings ComposerDependencyMappings `json:"dependencyMappings"`
+	}{s.mirrors, s.homeConfig, s.dependencyMappings})
+	if err != nil { // untested
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(content)), nil
+}
+
 func Build(
 	logger scribe.Emitter,
 	composerInstallOptions DetermineComposerInstallOptions,
@@ -50,24 +110,178 @@ func Build(
 	composerInstallExec Executable,
 	composerGlobalExec Executable,
 	checkPlatformReqsExec Executable,
+	composerAuditExec Executable,
+	composerRunScriptExec Executable,
+	composerVersionExec Executable,
 	sbomGenerator SBOMGenerator,
 	path string,
 	calculator Calculator,
+	bindingResolver BindingResolver,
 	clock chronos.Clock) packit.BuildFunc {
 	return func(context packit.BuildContext) (packit.BuildResult, error) {
 		logger.Title("%s %s", context.BuildpackInfo.Name, context.BuildpackInfo.Version)
+		startedOn := clock.Now()
+
+		caBundlePath, err := setupComposerCACertificates(logger, context, bindingResolver)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
 
-		composerPhpIniPath, err := writeComposerPhpIni(logger, context)
+		composerPhpIniPath, err := writeComposerPhpIni(logger, context, caBundlePath)
 		if err != nil { // untested
 			return packit.BuildResult{}, err
 		}
 
-		composerGlobalBin, err := runComposerGlobalIfRequired(logger, context, composerGlobalExec, path, composerPhpIniPath)
+		var settings composerSettings
+
+		settings.env, err = setupComposerAuth(logger, context, bindingResolver)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		settings.hermetic, settings.envAllowlist, err = parseComposerEnvironment()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if caBundlePath != "" {
+			settings.env = append(settings.env,
+				fmt.Sprintf("COMPOSER_CAFILE=%s", caBundlePath), // https://getcomposer.org/doc/03-cli.md#composer-cafile
+				fmt.Sprintf("SSL_CERT_FILE=%s", caBundlePath),   // used by Git
+			)
+		}
+
+		sshEnv, cleanupSSH, err := setupComposerSSH(logger, context, bindingResolver)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+		defer cleanupSSH()
+		settings.env = append(settings.env, sshEnv...)
+
+		settings.mirrors, err = parseComposerMirrors()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+		settings.homeConfig = composerMirrorHomeConfig(settings.mirrors)
+
+		auditPolicy, err := parseComposerAuditPolicy()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		attribution, err := attributionEnabled()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		provenanceLabel, err := provenanceLabelEnabled()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		abandonedPackages, err := checkAbandonedPackages(logger, context)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		denyRules, err := resolveComposerDenyList(context, bindingResolver)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		composerJsonPath, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
+		composerLock, err := ParseComposerLock(composerLockPath)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		hardened, err := hardenedModeEnabled()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if hardened {
+			policy, err := resolveComposerHardenedPolicy(composerJsonPath)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+			settings.hardened = &policy
+		}
+
+		if lockedPackages := composerLock.AllPackages(); len(lockedPackages) > 0 {
+			err = checkDeniedPackages(logger, denyRules, lockedPackages, DefaultComposerLockPath)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		settings.dependencyMappings, err = resolveComposerDependencyMappings(logger, context, bindingResolver)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if settings.dependencyMappings != nil {
+			settings.offline = true
+			// https://getcomposer.org/doc/03-cli.md#composer-disable-network
+			settings.env = append(settings.env, "COMPOSER_DISABLE_NETWORK=1")
+			settings.homeConfig = map[string]interface{}{
+				"repositories": []interface{}{
+					map[string]interface{}{"packagist.org": false},
+				},
+			}
+		}
+
+		repository, err := resolveComposerRepository(logger, context, bindingResolver)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if repository != nil {
+			if settings.offline {
+				return packit.BuildResult{}, fmt.Errorf("bindings of type '%s' and '%s' cannot be used together", composerRepositoryBindingType, composerDependencyMappingBindingType)
+			}
+
+			// every locked package must be in the repository, so that nothing is downloaded from elsewhere
+			settings.dependencyMappings, err = repository.DependencyMappings(composerLockPath,
+				filepath.Join(context.WorkingDir, ComposerGlobalManifestDir, DefaultComposerLockPath))
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+			settings.homeConfig = repository.HomeConfig()
+		}
+
+		// credentials must never reach the build log, whatever Composer prints
+		logger.ActionWriter = NewSecretMaskingWriter(logger.ActionWriter, composerAuthSecrets(append(os.Environ(), settings.env...))...)
+
+		var additionalLayers []packit.Layer
+
+		verifyDist, err := distVerificationEnabled()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		// the archives are only kept where the integrity check can find them when the cache directory is known
+		composerCacheDir, composerCacheDirSet := os.LookupEnv("COMPOSER_CACHE_DIR")
+		if verifyDist && !composerCacheDirSet {
+			composerCacheLayer, err := context.Layers.Get(ComposerCacheLayerName)
+			if err != nil { // untested
+				return packit.BuildResult{}, err
+			}
+			composerCacheLayer.Cache = true
+			additionalLayers = append(additionalLayers, composerCacheLayer)
+
+			composerCacheDir = composerCacheLayer.Path
+			// https://getcomposer.org/doc/03-cli.md#composer-cache-dir
+			settings.env = append(settings.env, fmt.Sprintf("COMPOSER_CACHE_DIR=%s", composerCacheDir))
+		}
+
+		composerGlobalLayer, composerGlobalBin, err := runComposerGlobalIfRequired(logger, context, composerGlobalExec, path, composerPhpIniPath, settings, calculator)
 		if err != nil { // untested
 			return packit.BuildResult{}, err
 		}
 
 		if composerGlobalBin != "" {
+			additionalLayers = append(additionalLayers, composerGlobalLayer)
 			path = strings.Join([]string{
 				composerGlobalBin,
 				path,
@@ -80,24 +294,39 @@ func Build(
 			workspaceVendorDir = filepath.Join(context.WorkingDir, value)
 		}
 
-		err = runCheckAndEnablePlatformReqs(logger, checkPlatformReqsExec, context.WorkingDir, composerPhpIniPath, path)
+		err = runCheckAndEnablePlatformReqs(logger, checkPlatformReqsExec, context.WorkingDir, composerPhpIniPath, path, settings)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		devForBuild, err := devPackagesForBuild()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		planMetadata, err := MergeComposerPackagesPlanMetadata(context.Plan.Entries)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
 		var composerPackagesLayer packit.Layer
+		var composerPackagesRebuilt bool
 		logger.Process("Executing build process")
 		duration, err := clock.Measure(func() error {
-			composerPackagesLayer, err = runComposerInstall(
+			composerPackagesLayer, composerPackagesRebuilt, err = runComposerInstall(
 				logger,
 				context,
 				composerInstallOptions,
+				planMetadata,
 				composerPhpIniPath,
 				path,
+				settings,
 				composerConfigExec,
 				composerInstallExec,
+				composerRunScriptExec,
 				workspaceVendorDir,
-				calculator)
+				calculator,
+				clock)
 			return err
 		})
 		if err != nil {
@@ -106,6 +335,94 @@ func Build(
 		logger.Action("Completed in %s", duration.Round(time.Millisecond))
 		logger.Break()
 
+		// lockless builds are only known after the install, and the lock may not match what was installed
+		installed, err := readComposerInstalled(workspaceVendorDir)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = checkDeniedPackages(logger, denyRules, installed.Packages, "vendor/composer/installed.json")
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if verifyDist {
+			err = verifyComposerIntegrity(logger, context, settings, composerCacheDir, installed)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		if devForBuild {
+			composerPackagesDevLayer, err := runComposerInstallDev(
+				logger,
+				context,
+				composerInstallOptions,
+				planMetadata,
+				composerPhpIniPath,
+				path,
+				settings,
+				composerInstallExec,
+				calculator)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+			additionalLayers = append(additionalLayers, composerPackagesDevLayer)
+		}
+
+		err = checkComposerLicenses(logger, context)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if attribution {
+			composerAttributionLayer, err := writeComposerAttribution(logger, context, workspaceVendorDir)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+			additionalLayers = append(additionalLayers, composerAttributionLayer)
+		}
+
+		if auditPolicy.Mode != ComposerAuditOff {
+			advisoryDatabase, err := resolveComposerAdvisoryDatabase(logger, context, bindingResolver)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			// lockless builds are not audited, since `composer audit --locked` needs a composer.lock
+			_, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
+			lockExists, err := fs.Exists(composerLockPath)
+			if err != nil { // untested
+				return packit.BuildResult{}, err
+			}
+
+			if !lockExists {
+				logger.Process("WARNING: skipping composer audit, since there is no %s", DefaultComposerLockPath)
+				logger.Break()
+			} else if settings.offline && advisoryDatabase == nil && auditPolicy.Mode == ComposerAuditFail {
+				return packit.BuildResult{}, fmt.Errorf("%s=%s cannot be used when installing packages offline without an advisory database, since composer audit needs to fetch advisories", BpComposerAudit, ComposerAuditFail)
+			} else if settings.offline && advisoryDatabase == nil {
+				logger.Process("WARNING: skipping composer audit, since packages are installed offline")
+				logger.Break()
+			} else {
+				composerAuditLayer, err := runComposerAudit(
+					logger,
+					context,
+					composerAuditExec,
+					advisoryDatabase,
+					auditPolicy,
+					composerPhpIniPath,
+					filepath.Join(composerPackagesLayer.Path, ".composer"),
+					path,
+					slices.Contains(composerInstallOptionsFromMetadata(composerPackagesLayer.Metadata["install-options"]), "--no-dev"),
+					settings)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+				additionalLayers = append(additionalLayers, composerAuditLayer)
+			}
+		}
+
 		logger.GeneratingSBOM(composerPackagesLayer.Path)
 
 		var sbomContent sbom.SBOM
@@ -126,81 +443,310 @@ func Build(
 			return packit.BuildResult{}, err
 		}
 
-		return packit.BuildResult{
-			Layers: []packit.Layer{
-				composerPackagesLayer,
-			},
-		}, nil
+		if len(abandonedPackages) > 0 {
+			composerPackagesLayer.SBOM = abandonedSBOMFormatter{formatter: composerPackagesLayer.SBOM, abandoned: abandonedPackages}
+		}
+
+		// the provenance describes the build that produced the vendor directory, so it is kept as is when the layer is reused
+		var provenance string
+		if composerPackagesRebuilt {
+			composerVersion, phpVersion, err := composerVersions(logger, context, composerVersionExec, composerPhpIniPath, path, settings)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			var globalPackages []ComposerLockPackage
+			if composerGlobalBin != "" {
+				installedGlobal, err := readComposerInstalled(filepath.Dir(composerGlobalBin))
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+				globalPackages = installedGlobal.Packages
+			}
+
+			logger.Process("Writing the provenance of the vendor directory to %s", filepath.Join(composerPackagesLayer.Path, ComposerProvenanceFile))
+			logger.Break()
+
+			provenance, err = writeComposerProvenance(context, composerPackagesLayer, globalPackages, composerVersion, phpVersion, settings, startedOn, clock.Now())
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		} else {
+			provenance, err = readComposerProvenance(logger, composerPackagesLayer)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		result := packit.BuildResult{
+			Layers: append([]packit.Layer{composerPackagesLayer}, additionalLayers...),
+		}
+
+		if provenanceLabel && provenance != "" {
+			result.Launch.Labels = map[string]string{ComposerProvenanceLabel: provenance}
+		}
+
+		return result, nil
 	}
 }
 
-// runComposerGlobalIfRequired will check for existence of env var "BP_COMPOSER_INSTALL_GLOBAL".
-// If that exists, will run `composer global require` with the contents of BP_COMPOSER_INSTALL_GLOBAL
-// to ensure that those packages are available for Composer scripts.
+// runComposerGlobalIfRequired will install global Composer packages so that they are available
+// for Composer scripts. The packages are taken from either:
+//   - a committed global manifest at .composer-global/composer.json (and its composer.lock),
+//     which is installed using `composer global install`
+//   - the env var "BP_COMPOSER_INSTALL_GLOBAL", whose contents are installed using `composer global require`
+//
+// The manifest takes precedence if both are present. The composer-global layer is cached by a checksum
+// of the manifest or of the env var, and the packages are only installed again when it changes.
 //
-// It will return the location to which the packages have been installed, so that they can be made available
-// on the path when running `composer install`.
+// It will return the layer and the location to which the packages have been installed, so that they can
+// be made available on the path when running `composer install`.
 //
-// `composer global require`: https://getcomposer.org/doc/03-cli.md#global
+// `composer global`: https://getcomposer.org/doc/03-cli.md#global
 // Composer scripts: https://getcomposer.org/doc/articles/scripts.md
 func runComposerGlobalIfRequired(
 	logger scribe.Emitter,
 	context packit.BuildContext,
 	composerGlobalExec Executable,
 	path string,
-	composerPhpIniPath string) (composerGlobalBin string, err error) {
+	composerPhpIniPath string,
+	settings composerSettings,
+	calculator Calculator) (composerGlobalLayer packit.Layer, composerGlobalBin string, err error) {
 	composerInstallGlobal, found := os.LookupEnv(BpComposerInstallGlobal)
 
-	if !found {
-		return "", nil
+	manifestPath := filepath.Join(context.WorkingDir, ComposerGlobalManifestDir, DefaultComposerJsonPath)
+	manifestLockPath := filepath.Join(context.WorkingDir, ComposerGlobalManifestDir, DefaultComposerLockPath)
+
+	manifestExists, err := fs.Exists(manifestPath)
+	if err != nil { // untested
+		return packit.Layer{}, "", err
 	}
 
-	composerGlobalLayer, err := context.Layers.Get(ComposerGlobalLayerName)
+	planPackages, err := MergeComposerGlobalPlanPackages(context.Plan.Entries)
+	if err != nil {
+		return packit.Layer{}, "", err
+	}
+
+	if !found && !manifestExists && len(planPackages) == 0 {
+		return packit.Layer{}, "", nil
+	}
+
+	launch, build, err := composerGlobalLayerTypes()
+	if err != nil {
+		return packit.Layer{}, "", err
+	}
+
+	planLaunch, planBuild := draft.NewPlanner().MergeLayerTypes(ComposerGlobalDependency, context.Plan.Entries)
+	launch, build = launch || planLaunch, build || planBuild
+
+	var planRequirements, conflicts []string
+	for _, p := range planPackages {
+		planRequirements = append(planRequirements, p.Requirement())
+		if len(p.Constraints) > 1 {
+			logger.Process("Package %s is requested with multiple constraints (%s), requiring all of them", p.Name, strings.Join(p.Constraints, ", "))
+			conflicts = append(conflicts, fmt.Sprintf("%s (%s)", p.Name, strings.Join(p.Constraints, ", ")))
+		}
+	}
+
+	var globalChecksum string
+	if manifestExists {
+		if found {
+			logger.Process("WARNING: ignoring %s because %s exists", BpComposerInstallGlobal, filepath.Join(ComposerGlobalManifestDir, DefaultComposerJsonPath))
+		}
+
+		manifestFiles := []string{manifestPath}
+		if exists, err := fs.Exists(manifestLockPath); err != nil { // untested
+			return packit.Layer{}, "", err
+		} else if exists {
+			manifestFiles = append(manifestFiles, manifestLockPath)
+		}
+
+		globalChecksum, err = calculator.Sum(manifestFiles...)
+		if err != nil { // untested
+			return packit.Layer{}, "", err
+		}
+	} else {
+		globalChecksum = fmt.Sprintf("%x", sha256.Sum256([]byte(composerInstallGlobal)))
+	}
+
+	if len(planRequirements) > 0 {
+		globalChecksum = fmt.Sprintf("%x", sha256.Sum256([]byte(globalChecksum+"\n"+strings.Join(planRequirements, " "))))
+	}
+
+	// a layer installed with plugins and scripts must not be reused in hardened mode
+	if settings.hardened != nil {
+		globalChecksum = fmt.Sprintf("%x", sha256.Sum256([]byte(globalChecksum+"\nhardened")))
+	}
+
+	logger.Debug.Process("Calculated checksum of %s for global Composer packages", globalChecksum)
+
+	// a layer installed from other mirrors or repositories must not be reused
+	sourcesChecksum, err := settings.sourcesChecksum()
 	if err != nil { // untested
-		return "", err
+		return packit.Layer{}, "", err
 	}
 
-	composerGlobalLayer, err = composerGlobalLayer.Reset()
+	composerGlobalLayer, err = context.Layers.Get(ComposerGlobalLayerName)
 	if err != nil { // untested
-		return "", err
+		return packit.Layer{}, "", err
 	}
 
-	globalPackages := strings.Split(composerInstallGlobal, " ")
-	args := append([]string{"global", "require", "--no-progress"}, globalPackages...)
-	logger.Process("Running 'composer %s'", strings.Join(args, " "))
+	composerGlobalBin = filepath.Join(composerGlobalLayer.Path, "vendor", "bin")
 
-	execution := pexec.Execution{
-		Args: args,
-		Dir:  composerGlobalLayer.Path,
-		Env: append(os.Environ(),
-			"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
-			fmt.Sprintf("COMPOSER_HOME=%s", composerGlobalLayer.Path),
-			fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
-			"COMPOSER_VENDOR_DIR=vendor", // ensure default in the layer
-			fmt.Sprintf("PATH=%s", path),
-		),
-		Stdout: logger.ActionWriter,
-		Stderr: logger.ActionWriter,
+	cachedSHA, _ := composerGlobalLayer.Metadata["global-sha"].(string)
+	cachedSourcesSHA, _ := composerGlobalLayer.Metadata["sources-sha"].(string)
+	stack, _ := composerGlobalLayer.Metadata["stack"].(string)
+	if cachedSHA == globalChecksum && cachedSourcesSHA == sourcesChecksum && stack == context.Stack {
+		logger.Process("Reusing cached layer %s", composerGlobalLayer.Path)
+	} else {
+		composerGlobalLayer, err = composerGlobalLayer.Reset()
+		if err != nil { // untested
+			return packit.Layer{}, "", err
+		}
+
+		err = writeComposerHomeConfig(composerGlobalLayer.Path, settings.homeConfig)
+		if err != nil { // untested
+			return packit.Layer{}, "", err
+		}
+
+		// in hardened mode, global packages run no plugins or scripts at all
+		globalOptions := []string{"--no-progress"}
+		if settings.hardened != nil {
+			globalOptions = hardenInstallArgs(globalOptions)
+		}
+
+		var commands [][]string
+		manifestLocked := false
+		if manifestExists {
+			err = fs.Copy(manifestPath, filepath.Join(composerGlobalLayer.Path, DefaultComposerJsonPath))
+			if err != nil { // untested
+				return packit.Layer{}, "", err
+			}
+
+			if exists, err := fs.Exists(manifestLockPath); err != nil { // untested
+				return packit.Layer{}, "", err
+			} else if exists {
+				manifestLocked = true
+				err = fs.Copy(manifestLockPath, filepath.Join(composerGlobalLayer.Path, DefaultComposerLockPath))
+				if err != nil { // untested
+					return packit.Layer{}, "", err
+				}
+
+				if settings.rewritesDistURLs() {
+					err = rewriteComposerLockURLs(logger, filepath.Join(composerGlobalLayer.Path, DefaultComposerLockPath), settings)
+					if err != nil {
+						return packit.Layer{}, "", err
+					}
+				}
+			}
+
+			commands = append(commands, append([]string{"global", "install"}, globalOptions...))
+			if len(planRequirements) > 0 {
+				commands = append(commands, append(append([]string{"global", "require"}, globalOptions...), planRequirements...))
+			}
+		} else {
+			var globalPackages []string
+			if found {
+				globalPackages = strings.Split(composerInstallGlobal, " ")
+			}
+			commands = append(commands, append(append(append([]string{"global", "require"}, globalOptions...), globalPackages...), planRequirements...))
+		}
+
+		// resolving packages that are not locked needs the network
+		if settings.offline && (!manifestLocked || len(commands) > 1) {
+			return packit.Layer{}, "", fmt.Errorf("global packages can only be installed from dependency mappings with a %s manifest that includes a %s",
+				ComposerGlobalManifestDir, DefaultComposerLockPath)
+		}
+
+		for _, args := range commands {
+			logger.Process("Running 'composer %s'", strings.Join(args, " "))
+
+			execution := pexec.Execution{
+				Args: args,
+				Dir:  composerGlobalLayer.Path,
+				Env: settings.environ(logger,
+					"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
+					fmt.Sprintf("COMPOSER_HOME=%s", composerGlobalLayer.Path),
+					fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
+					"COMPOSER_VENDOR_DIR=vendor", // ensure default in the layer
+					fmt.Sprintf("PATH=%s", path),
+				),
+				Stdout: logger.ActionWriter,
+				Stderr: logger.ActionWriter,
+			}
+			err = composerGlobalExec.Execute(execution)
+			flushActionWriter(logger)
+			if err != nil {
+				if len(conflicts) > 0 {
+					return packit.Layer{}, "", fmt.Errorf("%w: global packages requested with multiple constraints by the build plan may not be satisfiable together: %s", err, strings.Join(conflicts, ", "))
+				}
+				return packit.Layer{}, "", err
+			}
+		}
+
+		composerGlobalLayer.Metadata = map[string]interface{}{
+			"stack":      context.Stack,
+			"global-sha": globalChecksum,
+		}
+		if sourcesChecksum != "" {
+			composerGlobalLayer.Metadata["sources-sha"] = sourcesChecksum
+		}
 	}
-	err = composerGlobalExec.Execute(execution)
-	if err != nil {
-		return "", err
+
+	// the layer is cached so that the global packages are only installed again when they change
+	composerGlobalLayer.Cache = true
+	composerGlobalLayer.Launch, composerGlobalLayer.Build = launch, build
+
+	if build {
+		composerGlobalLayer.BuildEnv.Prepend("PATH", composerGlobalBin, string(os.PathListSeparator))
+	}
+	if launch {
+		composerGlobalLayer.LaunchEnv.Prepend("PATH", composerGlobalBin, string(os.PathListSeparator))
 	}
 
-	composerGlobalBin = filepath.Join(composerGlobalLayer.Path, "vendor", "bin")
+	logger.Debug.Subprocess("Setting layer types: launch=[%t], build=[%t], cache=[%t]",
+		composerGlobalLayer.Launch,
+		composerGlobalLayer.Build,
+		composerGlobalLayer.Cache)
+	logger.EnvironmentVariables(composerGlobalLayer)
 
 	if os.Getenv(BpLogLevel) == "DEBUG" {
 		logger.Debug.Subprocess("Adding global Composer packages to PATH:")
 		files, err := os.ReadDir(composerGlobalBin)
 		if err != nil { // untested
-			return "", err
+			return packit.Layer{}, "", err
 		}
 		for _, f := range files {
 			logger.Debug.Subprocess(fmt.Sprintf("- %s", f.Name()))
 		}
 	}
 
-	return
+	return composerGlobalLayer, composerGlobalBin, nil
+}
+
+// flushActionWriter writes any partial line of Composer output held back by the SecretMaskingWriter
+func flushActionWriter(logger scribe.Emitter) {
+	if writer, ok := logger.ActionWriter.(*SecretMaskingWriter); ok {
+		_ = writer.Flush()
+	}
+}
+
+// composerGlobalLayerTypes parses BP_COMPOSER_GLOBAL_LAYER_TYPES, a comma- or space-separated
+// list containing "build" and/or "launch"
+func composerGlobalLayerTypes() (launch, build bool, err error) {
+	value := os.Getenv(BpComposerGlobalLayerTypes)
+	for _, layerType := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		switch layerType {
+		case "build":
+			build = true
+		case "launch":
+			launch = true
+		default:
+			return false, false, fmt.Errorf("invalid value for %s: '%s', must contain only 'build' and/or 'launch'", BpComposerGlobalLayerTypes, value)
+		}
+	}
+
+	return launch, build, nil
 }
 
 // runComposerInstall will run `composer install` to download dependencie into
@@ -208,23 +754,33 @@ func runComposerGlobalIfRequired(
 //
 // Returns:
 // - composerPackagesLayer: a new layer into which the dependencies will be installed
+// - rebuilt: whether the layer was built again rather than reused
 // - err: any error
 func runComposerInstall(
 	logger scribe.Emitter,
 	context packit.BuildContext,
 	composerInstallOptions DetermineComposerInstallOptions,
+	planMetadata ComposerPackagesPlanMetadata,
 	composerPhpIniPath string,
 	path string,
+	settings composerSettings,
 	composerConfigExec Executable,
 	composerInstallExec Executable,
+	composerRunScriptExec Executable,
 	workspaceVendorDir string,
-	calculator Calculator) (composerPackagesLayer packit.Layer, err error) {
+	calculator Calculator,
+	clock chronos.Clock) (composerPackagesLayer packit.Layer, rebuilt bool, err error) {
 
 	launch, build := draft.NewPlanner().MergeLayerTypes(ComposerPackagesDependency, context.Plan.Entries)
 
+	restoreStrategy, err := vendorRestoreStrategy()
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
 	composerPackagesLayer, err = context.Layers.Get(ComposerPackagesLayerName)
 	if err != nil { // untested
-		return packit.Layer{}, err
+		return packit.Layer{}, false, err
 	}
 
 	composerJsonPath, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
@@ -233,7 +789,7 @@ func runComposerInstall(
 
 	composerLockChecksum, err := calculator.Sum(composerLockPath)
 	if err != nil { // untested
-		return packit.Layer{}, err
+		return packit.Layer{}, false, err
 	}
 
 	logger.Debug.Process("Calculated checksum of %s for composer.lock", composerLockChecksum)
@@ -244,8 +800,26 @@ func runComposerInstall(
 		logger.Debug.Process("Current stack: %s", context.Stack)
 	}
 
+	installArgs := append([]string{"install"}, composerInstallOptions.Determine(planMetadata)...)
+
+	// dev packages are installed into a separate build-only layer, so they must not end up in the workspace
+	devForBuild, err := devPackagesForBuild()
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+	if devForBuild && !slices.Contains(installArgs, "--no-dev") {
+		installArgs = append(installArgs, "--no-dev")
+	}
+
+	executionArgs := installArgs
+	if settings.hardened != nil {
+		executionArgs = hardenInstallArgs(installArgs)
+	}
+
 	cachedSHA, shaOk := composerPackagesLayer.Metadata["composer-lock-sha"].(string)
-	if (shaOk && cachedSHA == composerLockChecksum) && (stackOk && stack.(string) == context.Stack) {
+	cacheVerified := (stackOk && stack.(string) == context.Stack) && verifyCachedVendorDir(logger, composerPackagesLayer, layerVendorDir, calculator)
+
+	if cacheVerified && (shaOk && cachedSHA == composerLockChecksum) && installOptionsMatch(logger, composerPackagesLayer, executionArgs[1:]) {
 		logger.Process("Reusing cached layer %s", composerPackagesLayer.Path)
 		logger.Break()
 
@@ -262,7 +836,7 @@ func runComposerInstall(
 			logger.Debug.Subprocess("Listing files in %s:", composerPackagesLayer)
 			files, err := os.ReadDir(composerPackagesLayer.Path)
 			if err != nil { // untested
-				return packit.Layer{}, err
+				return packit.Layer{}, false, err
 			}
 			for _, f := range files {
 				logger.Debug.Subprocess(fmt.Sprintf("- %s", f.Name()))
@@ -270,26 +844,40 @@ func runComposerInstall(
 		}
 
 		if exists, err := fs.Exists(workspaceVendorDir); err != nil {
-			return packit.Layer{}, err
+			return packit.Layer{}, false, err
 		} else if exists {
 			logger.Process("Detected existing vendored packages, replacing with cached vendored packages")
 			if err := os.RemoveAll(workspaceVendorDir); err != nil { // untested
-				return packit.Layer{}, err
+				return packit.Layer{}, false, err
 			}
 		}
 
-		if err := fs.Copy(layerVendorDir, workspaceVendorDir); err != nil { // untested
-			return packit.Layer{}, err
+		if err := restoreVendorDir(logger, clock, restoreStrategy, layerVendorDir, workspaceVendorDir); err != nil { // untested
+			return packit.Layer{}, false, err
 		}
 
-		return composerPackagesLayer, nil
+		return composerPackagesLayer, false, nil
+	}
+
+	composerLock, err := ParseComposerLock(composerLockPath)
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
+	lockedPackages := installedPackagesFromLock(composerLock)
+
+	if cacheVerified {
+		err = seedVendorDirFromPreviousLayer(logger, clock, composerPackagesLayer, layerVendorDir, workspaceVendorDir, lockedPackages)
+		if err != nil {
+			return packit.Layer{}, false, err
+		}
 	}
 
 	logger.Process("Building new layer %s", composerPackagesLayer.Path)
 
 	composerPackagesLayer, err = composerPackagesLayer.Reset()
 	if err != nil { // untested
-		return packit.Layer{}, err
+		return packit.Layer{}, false, err
 	}
 
 	composerPackagesLayer.Launch, composerPackagesLayer.Build = launch, build
@@ -302,17 +890,27 @@ func runComposerInstall(
 		composerPackagesLayer.Cache)
 
 	composerPackagesLayer.Metadata = map[string]interface{}{
+		"metadata-version":  ComposerPackagesLayerMetadataVersion,
 		"stack":             context.Stack,
 		"composer-lock-sha": composerLockChecksum,
 	}
 
+	if len(lockedPackages) > 0 {
+		composerPackagesLayer.Metadata["packages"] = installedPackagesMetadata(lockedPackages)
+	}
+
+	err = writeComposerHomeConfig(filepath.Join(composerPackagesLayer.Path, ".composer"), settings.homeConfig)
+	if err != nil { // untested
+		return packit.Layer{}, false, err
+	}
+
 	args := []string{"config", "autoloader-suffix", ComposerAutoloaderSuffix}
 	logger.Process("Running 'composer %s'", strings.Join(args, " "))
 
 	execution := pexec.Execution{
 		Args: args,
 		Dir:  composerPackagesLayer.Path,
-		Env: append(os.Environ(),
+		Env: settings.environ(logger,
 			"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
 			fmt.Sprintf("COMPOSER=%s", composerJsonPath),
 			fmt.Sprintf("COMPOSER_HOME=%s", filepath.Join(composerPackagesLayer.Path, ".composer")),
@@ -325,8 +923,9 @@ func runComposerInstall(
 	}
 
 	err = composerConfigExec.Execute(execution)
+	flushActionWriter(logger)
 	if err != nil {
-		return packit.Layer{}, err
+		return packit.Layer{}, false, err
 	}
 
 	// `composer install` will run with `--no-autoloader` to avoid errors from
@@ -336,16 +935,26 @@ func runComposerInstall(
 	// set up, and then `composer dump-autoload` on the vendor directory from
 	// the working directory.
 
-	installArgs := append([]string{"install"}, composerInstallOptions.Determine()...)
-	logger.Process("Running 'composer %s'", strings.Join(installArgs, " "))
+	// the lock file in the application is left untouched, Composer installs from a copy using the mirrors or local archives
+	installComposerJsonPath := composerJsonPath
+	if settings.rewritesDistURLs() {
+		installComposerJsonPath, err = writeMirroredComposerFiles(logger, context, composerJsonPath, composerLockPath, settings)
+		if err != nil {
+			return packit.Layer{}, false, err
+		}
+	}
+
+	composerPackagesLayer.Metadata["install-options"] = executionArgs[1:]
+
+	logger.Process("Running 'composer %s'", strings.Join(executionArgs, " "))
 
 	// install packages into /workspace/vendor because composer cannot handle symlinks easily
 	execution = pexec.Execution{
-		Args: installArgs,
+		Args: executionArgs,
 		Dir:  context.WorkingDir,
-		Env: append(os.Environ(),
+		Env: settings.environ(logger,
 			"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
-			fmt.Sprintf("COMPOSER=%s", composerJsonPath),
+			fmt.Sprintf("COMPOSER=%s", installComposerJsonPath),
 			fmt.Sprintf("COMPOSER_HOME=%s", filepath.Join(composerPackagesLayer.Path, ".composer")),
 			fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", workspaceVendorDir),
 			fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
@@ -355,35 +964,314 @@ func runComposerInstall(
 		Stderr: logger.ActionWriter,
 	}
 	err = composerInstallExec.Execute(execution)
+	flushActionWriter(logger)
 	if err != nil {
-		return packit.Layer{}, err
+		return packit.Layer{}, false, err
 	}
 
-	logger.Process("Copying from %s => to %s", workspaceVendorDir, layerVendorDir)
+	if settings.hardened != nil {
+		err = runComposerHardenedScripts(logger, context, composerRunScriptExec, *settings.hardened, composerJsonPath, installArgs, execution.Env, workspaceVendorDir)
+		if err != nil {
+			return packit.Layer{}, false, err
+		}
+	}
 
-	err = fs.Copy(workspaceVendorDir, layerVendorDir)
+	err = restoreVendorDir(logger, clock, restoreStrategy, workspaceVendorDir, layerVendorDir)
 	if err != nil {
-		return packit.Layer{}, err
+		return packit.Layer{}, false, err
 	}
 
+	vendorChecksum, err := calculator.Sum(layerVendorDir)
+	if err != nil { // untested
+		return packit.Layer{}, false, err
+	}
+
+	logger.Debug.Process("Calculated checksum of %s for %s", vendorChecksum, layerVendorDir)
+	composerPackagesLayer.Metadata["vendor-sha"] = vendorChecksum
+
 	if os.Getenv(BpLogLevel) == "DEBUG" {
 		logger.Debug.Subprocess("Listing files in %s:", layerVendorDir)
 		files, err := os.ReadDir(layerVendorDir)
 		if err != nil { // untested
-			return packit.Layer{}, err
+			return packit.Layer{}, false, err
 		}
 		for _, f := range files {
 			logger.Debug.Subprocess(fmt.Sprintf("- %s", f.Name()))
 		}
 	}
 
-	return composerPackagesLayer, nil
+	return composerPackagesLayer, true, nil
+}
+
+// runComposerInstallDev will run `composer install` including the `require-dev` packages
+// into the vendor directory of a separate layer that is available during the build,
+// so that later build steps such as test runners or code generators can use them while
+// the vendor directory in the workspace, which is shipped at launch, is installed with `--no-dev`.
+//
+// The layer types are merged from the `composer-packages` requirements like those of the
+// composer-packages layer, except that the layer is always available during the build.
+// The vendor/bin directory of the layer is prepended to the PATH of later buildpacks.
+func runComposerInstallDev(
+	logger scribe.Emitter,
+	context packit.BuildContext,
+	composerInstallOptions DetermineComposerInstallOptions,
+	planMetadata ComposerPackagesPlanMetadata,
+	composerPhpIniPath string,
+	path string,
+	settings composerSettings,
+	composerInstallExec Executable,
+	calculator Calculator) (packit.Layer, error) {
+
+	launch, _ := draft.NewPlanner().MergeLayerTypes(ComposerPackagesDependency, context.Plan.Entries)
+
+	composerPackagesDevLayer, err := context.Layers.Get(ComposerPackagesDevLayerName)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	composerJsonPath, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
+	layerVendorDir := filepath.Join(composerPackagesDevLayer.Path, "vendor")
+
+	composerLockChecksum, err := calculator.Sum(composerLockPath)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	var installArgs []string
+	for _, option := range append([]string{"install"}, composerInstallOptions.Determine(planMetadata)...) {
+		if option != "--no-dev" {
+			installArgs = append(installArgs, option)
+		}
+	}
+	// plugins and scripts only run for the packages installed into the workspace
+	if settings.hardened != nil {
+		installArgs = hardenInstallArgs(installArgs)
+	}
+
+	stack, stackOk := composerPackagesDevLayer.Metadata["stack"].(string)
+	cachedSHA, shaOk := composerPackagesDevLayer.Metadata["composer-lock-sha"].(string)
+	if (shaOk && cachedSHA == composerLockChecksum) && (stackOk && stack == context.Stack) &&
+		installOptionsMatch(logger, composerPackagesDevLayer, installArgs[1:]) &&
+		verifyCachedVendorDir(logger, composerPackagesDevLayer, layerVendorDir, calculator) {
+		logger.Process("Reusing cached layer %s", composerPackagesDevLayer.Path)
+		logger.Break()
+	} else {
+		logger.Process("Building new layer %s", composerPackagesDevLayer.Path)
+
+		composerPackagesDevLayer, err = composerPackagesDevLayer.Reset()
+		if err != nil { // untested
+			return packit.Layer{}, err
+		}
+
+		err = writeComposerHomeConfig(filepath.Join(composerPackagesDevLayer.Path, ".composer"), settings.homeConfig)
+		if err != nil { // untested
+			return packit.Layer{}, err
+		}
+
+		installComposerJsonPath := composerJsonPath
+		if settings.rewritesDistURLs() {
+			installComposerJsonPath, err = writeMirroredComposerFiles(logger, context, composerJsonPath, composerLockPath, settings)
+			if err != nil {
+				return packit.Layer{}, err
+			}
+		}
+
+		logger.Process("Running 'composer %s'", strings.Join(installArgs, " "))
+
+		// install directly into the layer, so that Composer generates an autoloader
+		// that refers to the application in the workspace by its absolute path
+		execution := pexec.Execution{
+			Args: installArgs,
+			Dir:  context.WorkingDir,
+			Env: settings.environ(logger,
+				"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
+				fmt.Sprintf("COMPOSER=%s", installComposerJsonPath),
+				fmt.Sprintf("COMPOSER_HOME=%s", filepath.Join(composerPackagesDevLayer.Path, ".composer")),
+				fmt.Sprintf("COMPOSER_VENDOR_DIR=%s", layerVendorDir),
+				fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
+				fmt.Sprintf("PATH=%s", path),
+			),
+			Stdout: logger.ActionWriter,
+			Stderr: logger.ActionWriter,
+		}
+		err = composerInstallExec.Execute(execution)
+		flushActionWriter(logger)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+
+		vendorChecksum, err := calculator.Sum(layerVendorDir)
+		if err != nil { // untested
+			return packit.Layer{}, err
+		}
+
+		composerPackagesDevLayer.Metadata = map[string]interface{}{
+			"metadata-version":  ComposerPackagesLayerMetadataVersion,
+			"stack":             context.Stack,
+			"composer-lock-sha": composerLockChecksum,
+			"vendor-sha":        vendorChecksum,
+			"install-options":   installArgs[1:],
+		}
+	}
+
+	// the dev packages are installed for the build, so the layer is always available then
+	composerPackagesDevLayer.Launch, composerPackagesDevLayer.Build = launch, true
+	composerPackagesDevLayer.Cache = true
+	composerPackagesDevLayer.BuildEnv.Prepend("PATH", filepath.Join(layerVendorDir, "bin"), string(os.PathListSeparator))
+
+	logger.Debug.Subprocess("Setting layer types: launch=[%t], build=[%t], cache=[%t]",
+		composerPackagesDevLayer.Launch,
+		composerPackagesDevLayer.Build,
+		composerPackagesDevLayer.Cache)
+	logger.EnvironmentVariables(composerPackagesDevLayer)
+
+	return composerPackagesDevLayer, nil
+}
+
+// devPackagesForBuild reports whether BP_COMPOSER_INSTALL_DEV_FOR_BUILD is enabled
+func devPackagesForBuild() (bool, error) {
+	value, found := os.LookupEnv(BpComposerInstallDevForBuild)
+	if !found || value == "" {
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for %s: '%s', must be a boolean", BpComposerInstallDevForBuild, value)
+	}
+
+	return enabled, nil
+}
+
+// seedVendorDirFromPreviousLayer restores the vendor directory of the previous
+// composer-packages layer into the workspace when `composer.lock` has changed,
+// so that `composer install` only installs the packages that differ from the
+// previous build, and then regenerates the autoloader.
+//
+// Nothing is restored if the previous layer did not record its packages, none
+// of them are unchanged, or the workspace already contains vendored packages.
+func seedVendorDirFromPreviousLayer(
+	logger scribe.Emitter,
+	clock chronos.Clock,
+	composerPackagesLayer packit.Layer,
+	layerVendorDir string,
+	workspaceVendorDir string,
+	lockedPackages []installedPackage) error {
+
+	previousPackages := installedPackagesFromMetadata(composerPackagesLayer.Metadata["packages"])
+	if len(previousPackages) == 0 {
+		return nil
+	}
+
+	unchanged, changed := diffInstalledPackages(previousPackages, lockedPackages)
+	if len(unchanged) == 0 {
+		return nil
+	}
+
+	if exists, err := fs.Exists(workspaceVendorDir); err != nil {
+		return err
+	} else if exists {
+		logger.Debug.Process("Detected existing vendored packages, not reusing packages from the previous layer")
+		return nil
+	}
+
+	logger.Process("Reusing %d unchanged package(s) from the previous layer, %d package(s) are new or changed", len(unchanged), len(changed))
+	for _, name := range changed {
+		logger.Debug.Subprocess("- %s", name)
+	}
+
+	strategy, err := vendorRestoreStrategy()
+	if err != nil { // untested
+		return err
+	}
+
+	return restoreVendorDir(logger, clock, strategy, layerVendorDir, workspaceVendorDir)
+}
+
+// verifyCachedVendorDir checks that the vendor directory in a cached
+// composer-packages layer is still the one that was written when the layer was
+// built, for example after a build was killed while copying into the layer.
+//
+// If the layer metadata was written with another metadata version or the vendor
+// checksum does not match, a warning is logged and false is returned so that
+// the layer is rebuilt.
+func verifyCachedVendorDir(logger scribe.Emitter, composerPackagesLayer packit.Layer, layerVendorDir string, calculator Calculator) bool {
+	if !composerPackagesLayerMetadataSupported(composerPackagesLayer.Metadata) {
+		logger.Process("WARNING: cached layer metadata version '%v' is not supported, running 'composer install' again", composerPackagesLayer.Metadata["metadata-version"])
+		logger.Break()
+		return false
+	}
+
+	cachedVendorSHA, _ := composerPackagesLayer.Metadata["vendor-sha"].(string)
+
+	vendorChecksum, err := calculator.Sum(layerVendorDir)
+	if err != nil {
+		logger.Process("WARNING: unable to verify cached vendor directory: %s", err)
+		logger.Break()
+		return false
+	}
+
+	logger.Debug.Process("Calculated checksum of %s for cached %s", vendorChecksum, layerVendorDir)
+
+	if cachedVendorSHA != vendorChecksum {
+		logger.Process("WARNING: cached vendor directory does not match its recorded checksum, running 'composer install' again")
+		logger.Break()
+		return false
+	}
+
+	return true
+}
+
+// composerInstallOptionsFromMetadata reads the "install-options" layer metadata, as written by runComposerInstall
+// or as decoded from the layer TOML file
+func composerInstallOptionsFromMetadata(value interface{}) []string {
+	options := []string{}
+	switch v := value.(type) {
+	case []string:
+		options = append(options, v...)
+	case []interface{}:
+		for _, item := range v {
+			if option, ok := item.(string); ok {
+				options = append(options, option)
+			}
+		}
+	}
+	return options
+}
+
+// installOptionsMatch returns whether the packages in the layer were installed with the given `composer install` options
+func installOptionsMatch(logger scribe.Emitter, layer packit.Layer, options []string) bool {
+	cachedOptions := composerInstallOptionsFromMetadata(layer.Metadata["install-options"])
+	if !slices.Equal(cachedOptions, options) {
+		logger.Debug.Process("Install options changed from %v to %v", cachedOptions, options)
+		return false
+	}
+
+	return true
+}
+
+// composerPackagesLayerMetadataSupported returns whether composer-packages
+// layer metadata was written with the current ComposerPackagesLayerMetadataVersion.
+//
+// Metadata of any other version is not migrated, the layer is invalidated and
+// rebuilt instead. Metadata without a "metadata-version" key predates
+// versioning (version 1) and has no vendor checksum to verify against.
+func composerPackagesLayerMetadataSupported(metadata map[string]interface{}) bool {
+	var version int64 = 1
+	switch v := metadata["metadata-version"].(type) {
+	case int64:
+		version = v
+	case int:
+		version = int64(v)
+	}
+
+	return version == ComposerPackagesLayerMetadataVersion
 }
 
 // writeComposerPhpIni will create a PHP INI file used by Composer itself,
 // such as when running `composer global` and `composer install.
 // This is created in a new ignored layer.
-func writeComposerPhpIni(logger scribe.Emitter, context packit.BuildContext) (composerPhpIniPath string, err error) {
+func writeComposerPhpIni(logger scribe.Emitter, context packit.BuildContext, caBundlePath string) (composerPhpIniPath string, err error) {
 	composerPhpIniLayer, err := context.Layers.Get(ComposerPhpIniLayerName)
 	if err != nil { // untested
 		return "", err
@@ -402,6 +1290,9 @@ func writeComposerPhpIni(logger scribe.Emitter, context packit.BuildContext) (co
 	phpIni := fmt.Sprintf(`[PHP]
 extension_dir = "%s"
 extension = openssl.so`, os.Getenv(PhpExtensionDir))
+	if caBundlePath != "" {
+		phpIni += fmt.Sprintf("\nopenssl.cafile = \"%s\"", caBundlePath)
+	}
 	logger.Debug.Subprocess("Writing php.ini contents:\n'%s'", phpIni)
 
 	return composerPhpIniPath, os.WriteFile(composerPhpIniPath, []byte(phpIni), os.ModePerm)
@@ -421,7 +1312,7 @@ extension = openssl.so`, os.Getenv(PhpExtensionDir))
 // https://github.com/paketo-buildpacks/php-composer/blob/5e2604b74cbeb30090bf7eadb1cfc158b374efc0/composer/composer.go#L76-L100
 //
 // In case you are curious about exit code 2: https://getcomposer.org/doc/03-cli.md#process-exit-codes
-func runCheckAndEnablePlatformReqs(logger scribe.Emitter, checkPlatformReqsExec Executable, workingDir, composerPhpIniPath, path string) error {
+func runCheckAndEnablePlatformReqs(logger scribe.Emitter, checkPlatformReqsExec Executable, workingDir, composerPhpIniPath, path string, settings composerSettings) error {
 
 	args := []string{"check-platform-reqs"}
 	logger.Process("Running 'composer %s'", strings.Join(args, " "))
@@ -429,7 +1320,7 @@ func runCheckAndEnablePlatformReqs(logger scribe.Emitter, checkPlatformReqsExec
 	execution := pexec.Execution{
 		Args: args,
 		Dir:  workingDir,
-		Env: append(os.Environ(),
+		Env: settings.environ(logger,
 			"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
 			fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
 			fmt.Sprintf("PATH=%s", path),
@@ -439,6 +1330,7 @@ func runCheckAndEnablePlatformReqs(logger scribe.Emitter, checkPlatformReqsExec
 	}
 
 	err := checkPlatformReqsExec.Execute(execution)
+	flushActionWriter(logger)
 	if err != nil {
 		exitError, ok := err.(*exec.ExitError)
 		if !ok || exitError.ExitCode() != 2 {
@@ -1,5 +1,14 @@
 package composer
 
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
 // BuildPlanMetadata is the buildpack specific data included in build plan
 // requirements.
 type BuildPlanMetadata struct {
@@ -7,3 +16,159 @@ type BuildPlanMetadata struct {
 	Version       string `toml:"version"`
 	Build         bool   `toml:"build"`
 }
+
+const (
+	// AutoloadModeDefault generates the default autoloader
+	AutoloadModeDefault = "default"
+
+	// AutoloadModeOptimized runs `composer install` with `--optimize-autoloader`
+	AutoloadModeOptimized = "optimized"
+
+	// AutoloadModeClassmapAuthoritative runs `composer install` with `--classmap-authoritative`
+	AutoloadModeClassmapAuthoritative = "classmap-authoritative"
+)
+
+// autoloadModes lists the supported autoload modes, from least to most optimized
+var autoloadModes = []string{AutoloadModeDefault, AutoloadModeOptimized, AutoloadModeClassmapAuthoritative}
+
+// ComposerPackagesPlanMetadata holds the install options that downstream buildpacks
+// requested through the metadata of their `composer-packages` requirements.
+type ComposerPackagesPlanMetadata struct {
+	// Dev requests that the `require-dev` packages are installed
+	Dev bool
+
+	// AutoloadMode is one of AutoloadModeDefault, AutoloadModeOptimized or AutoloadModeClassmapAuthoritative
+	AutoloadMode string
+
+	// Scripts is nil when no requirement asked for scripts to be enabled or disabled
+	Scripts *bool
+}
+
+// MergeComposerPackagesPlanMetadata combines the `dev`, `autoload-mode` and `scripts`
+// metadata of all `composer-packages` entries in the buildpack plan:
+//   - dev: packages from `require-dev` are installed if any entry sets `dev = true`
+//   - autoload-mode: the most optimized mode requested by any entry is used
+//   - scripts: entries that set `scripts` must agree, otherwise an error is returned
+func MergeComposerPackagesPlanMetadata(entries []packit.BuildpackPlanEntry) (ComposerPackagesPlanMetadata, error) {
+	merged := ComposerPackagesPlanMetadata{
+		AutoloadMode: AutoloadModeDefault,
+	}
+
+	for _, entry := range entries {
+		if entry.Name != ComposerPackagesDependency {
+			continue
+		}
+
+		if value, ok := entry.Metadata["dev"]; ok {
+			dev, ok := value.(bool)
+			if !ok {
+				return ComposerPackagesPlanMetadata{}, fmt.Errorf("invalid %s metadata: 'dev' must be a boolean, got '%v'", ComposerPackagesDependency, value)
+			}
+			merged.Dev = merged.Dev || dev
+		}
+
+		if value, ok := entry.Metadata["autoload-mode"]; ok {
+			mode, _ := value.(string)
+			rank := slices.Index(autoloadModes, mode)
+			if rank < 0 {
+				return ComposerPackagesPlanMetadata{}, fmt.Errorf("invalid %s metadata: 'autoload-mode' must be one of %q, got '%v'", ComposerPackagesDependency, autoloadModes, value)
+			}
+			if rank > slices.Index(autoloadModes, merged.AutoloadMode) {
+				merged.AutoloadMode = mode
+			}
+		}
+
+		if value, ok := entry.Metadata["scripts"]; ok {
+			scripts, ok := value.(bool)
+			if !ok {
+				return ComposerPackagesPlanMetadata{}, fmt.Errorf("invalid %s metadata: 'scripts' must be a boolean, got '%v'", ComposerPackagesDependency, value)
+			}
+			if merged.Scripts != nil && *merged.Scripts != scripts {
+				return ComposerPackagesPlanMetadata{}, fmt.Errorf("conflicting %s metadata: 'scripts' is requested to be both enabled and disabled", ComposerPackagesDependency)
+			}
+			merged.Scripts = &scripts
+		}
+	}
+
+	return merged, nil
+}
+
+// ComposerGlobalPlanPackage is a global package requested through the `packages`
+// metadata of `composer-global` requirements, e.g. `packages = ["phpstan/phpstan:^1.10"]`.
+type ComposerGlobalPlanPackage struct {
+	Name string
+
+	// Constraints are the distinct version constraints requested for the package, if any
+	Constraints []string
+}
+
+// Requirement returns the argument for `composer global require`. Multiple constraints
+// are combined with a comma, which Composer treats as a logical AND.
+func (p ComposerGlobalPlanPackage) Requirement() string {
+	if len(p.Constraints) == 0 {
+		return p.Name
+	}
+	return fmt.Sprintf("%s:%s", p.Name, strings.Join(p.Constraints, ","))
+}
+
+// MergeComposerGlobalPlanPackages combines the `packages` metadata of all `composer-global`
+// entries in the buildpack plan. Packages requested by several entries are installed once,
+// with the constraints of every entry that requested them. The result is sorted by name.
+func MergeComposerGlobalPlanPackages(entries []packit.BuildpackPlanEntry) ([]ComposerGlobalPlanPackage, error) {
+	packagesByName := map[string]*ComposerGlobalPlanPackage{}
+
+	for _, entry := range entries {
+		if entry.Name != ComposerGlobalDependency {
+			continue
+		}
+
+		value, ok := entry.Metadata["packages"]
+		if !ok {
+			continue
+		}
+
+		var requirements []string
+		switch v := value.(type) {
+		case []string:
+			requirements = v
+		case []interface{}:
+			for _, item := range v {
+				requirement, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid %s metadata: 'packages' must be a list of strings, got '%v'", ComposerGlobalDependency, value)
+				}
+				requirements = append(requirements, requirement)
+			}
+		default:
+			return nil, fmt.Errorf("invalid %s metadata: 'packages' must be a list of strings, got '%v'", ComposerGlobalDependency, value)
+		}
+
+		for _, requirement := range requirements {
+			name, constraint, _ := strings.Cut(requirement, ":")
+			// package names are case-insensitive in Composer
+			name = strings.ToLower(strings.TrimSpace(name))
+			constraint = strings.TrimSpace(constraint)
+			if !strings.Contains(name, "/") {
+				return nil, fmt.Errorf("invalid %s metadata: '%s' must be of the form 'vendor/package' or 'vendor/package:constraint'", ComposerGlobalDependency, requirement)
+			}
+
+			p, ok := packagesByName[name]
+			if !ok {
+				p = &ComposerGlobalPlanPackage{Name: name}
+				packagesByName[name] = p
+			}
+
+			if constraint != "" && constraint != "*" && !slices.Contains(p.Constraints, constraint) {
+				p.Constraints = append(p.Constraints, constraint)
+			}
+		}
+	}
+
+	var packages []ComposerGlobalPlanPackage
+	for _, p := range packagesByName {
+		packages = append(packages, *p)
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+
+	return packages, nil
+}
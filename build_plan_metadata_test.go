@@ -0,0 +1,98 @@
+package composer_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testComposerPackagesPlanMetadata(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+	)
+
+	context("MergeComposerPackagesPlanMetadata", func() {
+		it("returns the defaults when no entry has metadata", func() {
+			merged, err := composer.MergeComposerPackagesPlanMetadata([]packit.BuildpackPlanEntry{
+				{Name: composer.ComposerPackagesDependency, Metadata: map[string]interface{}{"launch": true}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(merged).To(Equal(composer.ComposerPackagesPlanMetadata{
+				AutoloadMode: composer.AutoloadModeDefault,
+			}))
+		})
+
+		it("merges the metadata of all composer-packages entries", func() {
+			merged, err := composer.MergeComposerPackagesPlanMetadata([]packit.BuildpackPlanEntry{
+				{Name: composer.ComposerPackagesDependency, Metadata: map[string]interface{}{"dev": false, "autoload-mode": "classmap-authoritative", "scripts": false}},
+				{Name: composer.ComposerPackagesDependency, Metadata: map[string]interface{}{"dev": true, "autoload-mode": "optimized"}},
+				{Name: "something-else", Metadata: map[string]interface{}{"scripts": true}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(merged.Dev).To(BeTrue())
+			Expect(merged.AutoloadMode).To(Equal(composer.AutoloadModeClassmapAuthoritative))
+			Expect(merged.Scripts).To(Equal(new(bool)))
+		})
+
+		context("failure cases", func() {
+			it("returns an error when entries disagree about scripts", func() {
+				_, err := composer.MergeComposerPackagesPlanMetadata([]packit.BuildpackPlanEntry{
+					{Name: composer.ComposerPackagesDependency, Metadata: map[string]interface{}{"scripts": false}},
+					{Name: composer.ComposerPackagesDependency, Metadata: map[string]interface{}{"scripts": true}},
+				})
+				Expect(err).To(MatchError("conflicting composer-packages metadata: 'scripts' is requested to be both enabled and disabled"))
+			})
+
+			it("returns an error for an unknown autoload mode", func() {
+				_, err := composer.MergeComposerPackagesPlanMetadata([]packit.BuildpackPlanEntry{
+					{Name: composer.ComposerPackagesDependency, Metadata: map[string]interface{}{"autoload-mode": "fast"}},
+				})
+				Expect(err).To(MatchError(`invalid composer-packages metadata: 'autoload-mode' must be one of ["default" "optimized" "classmap-authoritative"], got 'fast'`))
+			})
+
+			it("returns an error when dev is not a boolean", func() {
+				_, err := composer.MergeComposerPackagesPlanMetadata([]packit.BuildpackPlanEntry{
+					{Name: composer.ComposerPackagesDependency, Metadata: map[string]interface{}{"dev": "yes"}},
+				})
+				Expect(err).To(MatchError("invalid composer-packages metadata: 'dev' must be a boolean, got 'yes'"))
+			})
+		})
+	})
+	context("MergeComposerGlobalPlanPackages", func() {
+		it("merges the packages of all composer-global entries", func() {
+			packages, err := composer.MergeComposerGlobalPlanPackages([]packit.BuildpackPlanEntry{
+				{Name: composer.ComposerGlobalDependency, Metadata: map[string]interface{}{"packages": []interface{}{"phpstan/phpstan:^1.10", "Drush/Drush"}}},
+				{Name: composer.ComposerGlobalDependency, Metadata: map[string]interface{}{"packages": []string{"phpstan/phpstan:^1.10", "phpstan/phpstan:<1.11", "drush/drush:*"}}},
+				{Name: composer.ComposerGlobalDependency, Metadata: map[string]interface{}{"build": true}},
+				{Name: composer.ComposerPackagesDependency, Metadata: map[string]interface{}{"packages": []interface{}{"ignored/package"}}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(packages).To(Equal([]composer.ComposerGlobalPlanPackage{
+				{Name: "drush/drush"},
+				{Name: "phpstan/phpstan", Constraints: []string{"^1.10", "<1.11"}},
+			}))
+			Expect(packages[0].Requirement()).To(Equal("drush/drush"))
+			Expect(packages[1].Requirement()).To(Equal("phpstan/phpstan:^1.10,<1.11"))
+		})
+
+		context("failure cases", func() {
+			it("returns an error when packages is not a list of strings", func() {
+				_, err := composer.MergeComposerGlobalPlanPackages([]packit.BuildpackPlanEntry{
+					{Name: composer.ComposerGlobalDependency, Metadata: map[string]interface{}{"packages": "phpstan/phpstan"}},
+				})
+				Expect(err).To(MatchError("invalid composer-global metadata: 'packages' must be a list of strings, got 'phpstan/phpstan'"))
+			})
+
+			it("returns an error for a package without a vendor", func() {
+				_, err := composer.MergeComposerGlobalPlanPackages([]packit.BuildpackPlanEntry{
+					{Name: composer.ComposerGlobalDependency, Metadata: map[string]interface{}{"packages": []interface{}{"phpstan:^1.10"}}},
+				})
+				Expect(err).To(MatchError("invalid composer-global metadata: 'phpstan:^1.10' must be of the form 'vendor/package' or 'vendor/package:constraint'"))
+			})
+		})
+	})
+}
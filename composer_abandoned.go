@@ -0,0 +1,190 @@
+package composer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// Values of BP_COMPOSER_ABANDONED
+const (
+	ComposerAbandonedIgnore = "ignore"
+	ComposerAbandonedWarn   = "warn"
+	ComposerAbandonedFail   = "fail"
+)
+
+// ComposerAbandoned is the `abandoned` field of a locked package, which is either a boolean or the name
+// of the package that replaces it
+type ComposerAbandoned struct {
+	Abandoned   bool
+	Replacement string
+}
+
+// UnmarshalJSON reads `true`, `false` or the name of a replacement package
+func (a *ComposerAbandoned) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case bool:
+		*a = ComposerAbandoned{Abandoned: v}
+	case string:
+		*a = ComposerAbandoned{Abandoned: true, Replacement: v}
+	default:
+		*a = ComposerAbandoned{}
+	}
+
+	return nil
+}
+
+// MarshalJSON writes the field the way composer.lock does
+func (a ComposerAbandoned) MarshalJSON() ([]byte, error) {
+	if a.Replacement != "" {
+		return json.Marshal(a.Replacement)
+	}
+	return json.Marshal(a.Abandoned)
+}
+
+// composerAbandonedMode reads BP_COMPOSER_ABANDONED, which defaults to "warn"
+func composerAbandonedMode() (string, error) {
+	value, found := os.LookupEnv(BpComposerAbandoned)
+	if !found || value == "" {
+		return ComposerAbandonedWarn, nil
+	}
+
+	mode := strings.ToLower(strings.TrimSpace(value))
+	switch mode {
+	case ComposerAbandonedIgnore, ComposerAbandonedWarn, ComposerAbandonedFail:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid value for %s: '%s', must be one of '%s', '%s' or '%s'",
+			BpComposerAbandoned, value, ComposerAbandonedIgnore, ComposerAbandonedWarn, ComposerAbandonedFail)
+	}
+}
+
+// checkAbandonedPackages reports the abandoned packages in the composer.lock of the application with their
+// suggested replacements, according to BP_COMPOSER_ABANDONED. It returns the abandoned packages by
+// lowercase name, so that they can be marked in the SBOM. composer.lock is not read when they are ignored.
+func checkAbandonedPackages(logger scribe.Emitter, context packit.BuildContext) (map[string]ComposerAbandoned, error) {
+	mode, err := composerAbandonedMode()
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == ComposerAbandonedIgnore {
+		return nil, nil
+	}
+
+	_, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
+	lock, err := ParseComposerLock(composerLockPath)
+	if err != nil {
+		return nil, err
+	}
+
+	abandoned := map[string]ComposerAbandoned{}
+	var descriptions []string
+	for _, p := range lock.AllPackages() {
+		if !p.Abandoned.Abandoned {
+			continue
+		}
+		abandoned[strings.ToLower(p.Name)] = p.Abandoned
+
+		description := fmt.Sprintf("%s %s (no replacement suggested)", p.Name, p.Version)
+		if p.Abandoned.Replacement != "" {
+			description = fmt.Sprintf("%s %s (use %s instead)", p.Name, p.Version, p.Abandoned.Replacement)
+		}
+		descriptions = append(descriptions, description)
+	}
+	sort.Strings(descriptions)
+
+	if len(descriptions) == 0 {
+		return abandoned, nil
+	}
+
+	if mode == ComposerAbandonedFail {
+		return nil, fmt.Errorf("found %d abandoned package(s) in composer.lock:\n  %s", len(descriptions), strings.Join(descriptions, "\n  "))
+	}
+
+	logger.Process("WARNING: found %d abandoned package(s) in composer.lock", len(descriptions))
+	for _, description := range descriptions {
+		logger.Subprocess(description)
+	}
+	logger.Break()
+
+	return abandoned, nil
+}
+
+// abandonedSBOMFormatter marks the abandoned packages in the CycloneDX SBOM with the properties
+// `composer:abandoned` and `composer:replacement`. Other formats are passed through.
+type abandonedSBOMFormatter struct {
+	formatter packit.SBOMFormatter
+	abandoned map[string]ComposerAbandoned
+}
+
+func (f abandonedSBOMFormatter) Formats() []packit.SBOMFormat {
+	var formats []packit.SBOMFormat
+	for _, format := range f.formatter.Formats() {
+		if format.Extension == sbom.Format(sbom.CycloneDXFormat).Extension() {
+			format.Content = f.decorate(format.Content)
+		}
+		formats = append(formats, format)
+	}
+	return formats
+}
+
+// decorate adds the properties to the components with a `pkg:composer` package URL of an abandoned package.
+// The document is returned unchanged if it cannot be parsed.
+func (f abandonedSBOMFormatter) decorate(content io.Reader) io.Reader {
+	original, err := io.ReadAll(content)
+	if err != nil { // untested
+		return bytes.NewReader(original)
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(original, &document); err != nil {
+		return bytes.NewReader(original)
+	}
+
+	components, _ := document["components"].([]interface{})
+	for _, item := range components {
+		component, _ := item.(map[string]interface{})
+		purl, _ := component["purl"].(string)
+
+		name, found := strings.CutPrefix(purl, "pkg:composer/")
+		if !found {
+			continue
+		}
+		name, _, _ = strings.Cut(name, "@")
+		name, _, _ = strings.Cut(name, "?")
+
+		abandoned, ok := f.abandoned[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+
+		properties, _ := component["properties"].([]interface{})
+		properties = append(properties, map[string]interface{}{"name": "composer:abandoned", "value": "true"})
+		if abandoned.Replacement != "" {
+			properties = append(properties, map[string]interface{}{"name": "composer:replacement", "value": abandoned.Replacement})
+		}
+		component["properties"] = properties
+	}
+
+	decorated, err := json.Marshal(document)
+	if err != nil { // untested
+		return bytes.NewReader(original)
+	}
+
+	return bytes.NewReader(decorated)
+}
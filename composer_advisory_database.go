@@ -0,0 +1,205 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"gopkg.in/yaml.v3"
+)
+
+// composerAdvisoryDatabaseBindingType is the service binding type that provides a security advisory database
+// in the layout of https://github.com/FriendsOfPHP/security-advisories
+const composerAdvisoryDatabaseBindingType = "composer-advisory-database"
+
+// friendsOfPHPSourceName is the name Composer gives advisories from the FriendsOfPHP/security-advisories database
+const friendsOfPHPSourceName = "FriendsOfPHP/security-advisories"
+
+// ComposerAdvisoryDatabase is a local copy of the FriendsOfPHP/security-advisories database, which holds
+// a YAML file per advisory in a directory per package, e.g. `symfony/http-kernel/CVE-2022-24894.yaml`
+type ComposerAdvisoryDatabase struct {
+	Dir string
+}
+
+// friendsOfPHPAdvisory is an advisory file of the FriendsOfPHP/security-advisories database
+type friendsOfPHPAdvisory struct {
+	Title     string `yaml:"title"`
+	Link      string `yaml:"link"`
+	CVE       string `yaml:"cve"`
+	Reference string `yaml:"reference"`
+	Branches  map[string]struct {
+		Time     string   `yaml:"time"`
+		Versions []string `yaml:"versions"`
+	} `yaml:"branches"`
+}
+
+// resolveComposerAdvisoryDatabase returns the advisory database from BP_COMPOSER_AUDIT_DATABASE, or else from
+// a `composer-advisory-database` service binding: either the binding itself or the directory given by its
+// `path` entry. It returns nil when no database is configured.
+func resolveComposerAdvisoryDatabase(logger scribe.Emitter, context packit.BuildContext, bindingResolver BindingResolver) (*ComposerAdvisoryDatabase, error) {
+	var dir, origin string
+
+	if value, found := os.LookupEnv(BpComposerAuditDatabase); found && value != "" {
+		dir = value
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(context.WorkingDir, dir)
+		}
+		origin = BpComposerAuditDatabase
+	} else {
+		bindings, err := bindingResolver.Resolve(composerAdvisoryDatabaseBindingType, "", context.Platform.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(bindings) == 0 {
+			return nil, nil
+		}
+
+		if len(bindings) > 1 {
+			return nil, fmt.Errorf("found %d bindings of type '%s', only one is supported", len(bindings), composerAdvisoryDatabaseBindingType)
+		}
+		binding := bindings[0]
+
+		dir = binding.Path
+		if entry, ok := binding.Entries["path"]; ok {
+			value, err := entry.ReadString()
+			if err != nil { // untested
+				return nil, err
+			}
+			dir = strings.TrimSpace(value)
+		}
+		origin = fmt.Sprintf("service binding '%s'", binding.Name)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("the advisory database at %s from %s is not a directory", dir, origin)
+	}
+
+	logger.Process("Using the advisory database at %s from %s", dir, origin)
+
+	return &ComposerAdvisoryDatabase{Dir: dir}, nil
+}
+
+// Audit returns the advisories in the database that affect the versions of the packages in the given
+// composer.lock, leaving out the `require-dev` packages with noDev, like `composer audit --no-dev`.
+// Packages installed from branches are skipped, since their version cannot be compared.
+func (d ComposerAdvisoryDatabase) Audit(logger scribe.Emitter, composerLockPath string, noDev bool) ([]ComposerAdvisory, error) {
+	lock, err := ParseComposerLock(composerLockPath)
+	if err != nil {
+		return nil, err
+	}
+
+	packages := lock.AllPackages()
+	if noDev {
+		packages = lock.Packages
+	}
+
+	var advisories []ComposerAdvisory
+	for _, p := range packages {
+		name := strings.ToLower(p.Name)
+
+		files, err := filepath.Glob(filepath.Join(d.Dir, filepath.FromSlash(name), "*.y*ml"))
+		if err != nil { // untested
+			return nil, err
+		}
+
+		if len(files) == 0 {
+			continue
+		}
+
+		version, err := parseComposerVersion(p.Version)
+		if err != nil {
+			logger.Debug.Subprocess("Skipping %s %s, its version cannot be compared against advisories", p.Name, p.Version)
+			continue
+		}
+
+		sort.Strings(files)
+		for _, file := range files {
+			advisory, affected, err := d.check(file, name, version)
+			if err != nil {
+				return nil, err
+			}
+
+			if affected {
+				advisories = append(advisories, advisory)
+			}
+		}
+	}
+
+	sort.Slice(advisories, func(i, j int) bool {
+		if advisories[i].PackageName != advisories[j].PackageName {
+			return advisories[i].PackageName < advisories[j].PackageName
+		}
+		return advisories[i].AdvisoryID < advisories[j].AdvisoryID
+	})
+
+	return advisories, nil
+}
+
+// check returns the advisory in the given file, and whether it affects the version of the package
+func (d ComposerAdvisoryDatabase) check(file, name string, version composerVersion) (ComposerAdvisory, bool, error) {
+	content, err := os.ReadFile(file)
+	if err != nil { // untested
+		return ComposerAdvisory{}, false, err
+	}
+
+	var entry friendsOfPHPAdvisory
+	err = yaml.Unmarshal(content, &entry)
+	if err != nil {
+		return ComposerAdvisory{}, false, fmt.Errorf("failed to parse advisory %s: %w", file, err)
+	}
+
+	// advisories may be stored with the package they apply to, rather than in its own directory
+	if reference, found := strings.CutPrefix(entry.Reference, "composer://"); found && !strings.EqualFold(reference, name) {
+		return ComposerAdvisory{}, false, nil
+	}
+
+	var branches []string
+	for branch := range entry.Branches {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+
+	remoteID, err := filepath.Rel(d.Dir, file)
+	if err != nil { // untested
+		return ComposerAdvisory{}, false, err
+	}
+	remoteID = filepath.ToSlash(remoteID)
+
+	advisory := ComposerAdvisory{
+		AdvisoryID:  remoteID,
+		PackageName: name,
+		Title:       entry.Title,
+		CVE:         entry.CVE,
+		Link:        entry.Link,
+		Sources:     []ComposerAdvisorySource{{Name: friendsOfPHPSourceName, RemoteID: remoteID}},
+	}
+
+	var ranges []string
+	affected := false
+	for _, branch := range branches {
+		conditions := entry.Branches[branch].Versions
+		ranges = append(ranges, strings.Join(conditions, ","))
+
+		matches, err := matchesComposerVersionConditions(version, conditions)
+		if err != nil {
+			return ComposerAdvisory{}, false, fmt.Errorf("failed to check advisory %s: %w", file, err)
+		}
+
+		if matches && !affected {
+			affected = true
+			if reportedAt, err := time.Parse(time.DateTime, entry.Branches[branch].Time); err == nil {
+				advisory.ReportedAt = reportedAt.Format(time.RFC3339)
+			}
+		}
+	}
+	advisory.AffectedVersions = strings.Join(ranges, "|")
+
+	return advisory, affected, nil
+}
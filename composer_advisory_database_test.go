@@ -0,0 +1,144 @@
+package composer_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testComposerAdvisoryDatabase(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		databaseDir      string
+		composerLockPath string
+		database         composer.ComposerAdvisoryDatabase
+		buffer           *bytes.Buffer
+	)
+
+	it.Before(func() {
+		databaseDir = t.TempDir()
+		Expect(os.MkdirAll(filepath.Join(databaseDir, "symfony", "http-kernel"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(databaseDir, "symfony", "http-kernel", "CVE-2022-24894.yaml"), []byte(`title:     'CVE-2022-24894: Prevent storing cookie headers in HttpCache'
+link:      https://symfony.com/cve-2022-24894
+cve:       CVE-2022-24894
+branches:
+    4.4.x:
+        time:     2023-02-01 08:00:00
+        versions: ['>=4.4.0', '<4.4.50']
+    6.2.x:
+        time:     2023-02-01 09:00:00
+        versions: ['>=6.2.0', '<6.2.6']
+reference: composer://symfony/http-kernel
+`), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(databaseDir, "symfony", "http-kernel", "CVE-2019-18888.yaml"), []byte(`title:     'CVE-2019-18888: Prevent argument injection in a MimeTypeGuesser'
+link:      https://symfony.com/cve-2019-18888
+cve:       CVE-2019-18888
+branches:
+    4.3.x:
+        time:     2019-11-12 00:00:00
+        versions: ['>=4.3.0', '<4.3.8']
+reference: composer://symfony/http-kernel
+`), 0644)).To(Succeed())
+
+		Expect(os.MkdirAll(filepath.Join(databaseDir, "guzzlehttp", "psr7"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(databaseDir, "guzzlehttp", "psr7", "CVE-2023-29197.yaml"), []byte(`title:     Improper header validation
+link:      https://github.com/guzzle/psr7/security/advisories/GHSA-wxmh-65f7-jcvw
+cve:       CVE-2023-29197
+branches:
+    1.x:
+        time:     ~
+        versions: ['<1.9.1']
+    2.x:
+        time:     ~
+        versions: ['>=2', '<2.4.5']
+reference: composer://guzzlehttp/psr7
+`), 0644)).To(Succeed())
+
+		composerLockPath = filepath.Join(t.TempDir(), "composer.lock")
+		Expect(os.WriteFile(composerLockPath, []byte(`{
+			"packages": [
+				{"name": "Symfony/Http-Kernel", "version": "v6.2.0"},
+				{"name": "monolog/monolog", "version": "3.3.1"}
+			],
+			"packages-dev": [
+				{"name": "guzzlehttp/psr7", "version": "2.5.0"}
+			]
+		}`), 0644)).To(Succeed())
+
+		database = composer.ComposerAdvisoryDatabase{Dir: databaseDir}
+		buffer = bytes.NewBuffer(nil)
+	})
+
+	it("returns the advisories affecting the locked versions", func() {
+		advisories, err := database.Audit(scribe.NewEmitter(buffer), composerLockPath, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(advisories).To(Equal([]composer.ComposerAdvisory{
+			{
+				AdvisoryID:       "symfony/http-kernel/CVE-2022-24894.yaml",
+				PackageName:      "symfony/http-kernel",
+				AffectedVersions: ">=4.4.0,<4.4.50|>=6.2.0,<6.2.6",
+				Title:            "CVE-2022-24894: Prevent storing cookie headers in HttpCache",
+				CVE:              "CVE-2022-24894",
+				Link:             "https://symfony.com/cve-2022-24894",
+				ReportedAt:       "2023-02-01T09:00:00Z",
+				Sources:          []composer.ComposerAdvisorySource{{Name: "FriendsOfPHP/security-advisories", RemoteID: "symfony/http-kernel/CVE-2022-24894.yaml"}},
+			},
+		}))
+	})
+
+	context("when a dev package is affected", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(composerLockPath, []byte(`{"packages-dev": [{"name": "guzzlehttp/psr7", "version": "2.4.1"}]}`), 0644)).To(Succeed())
+		})
+
+		it("returns its advisories", func() {
+			advisories, err := database.Audit(scribe.NewEmitter(buffer), composerLockPath, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(advisories).To(HaveLen(1))
+			Expect(advisories[0].PackageName).To(Equal("guzzlehttp/psr7"))
+		})
+
+		it("skips it when the dev packages are not installed", func() {
+			advisories, err := database.Audit(scribe.NewEmitter(buffer), composerLockPath, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(advisories).To(BeEmpty())
+		})
+	})
+
+	context("when a package is installed from a branch", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(composerLockPath, []byte(`{"packages": [{"name": "guzzlehttp/psr7", "version": "2.x-dev"}]}`), 0644)).To(Succeed())
+		})
+
+		it("skips it", func() {
+			advisories, err := database.Audit(scribe.NewEmitter(buffer).WithLevel("DEBUG"), composerLockPath, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(advisories).To(BeEmpty())
+			Expect(buffer.String()).To(ContainSubstring("Skipping guzzlehttp/psr7 2.x-dev, its version cannot be compared against advisories"))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when an advisory has an invalid version condition", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(databaseDir, "guzzlehttp", "psr7", "CVE-2023-29197.yaml"), []byte(`branches:
+    2.x:
+        versions: ['~2.0']
+`), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := database.Audit(scribe.NewEmitter(buffer), composerLockPath, false)
+				Expect(err).To(MatchError(ContainSubstring("invalid version condition '~2.0'")))
+			})
+		})
+	})
+}
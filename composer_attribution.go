@@ -0,0 +1,229 @@
+package composer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// licenseFilePrefixes are the names of the files in a package that hold its license texts, such as
+// LICENSE, LICENSE.md, LICENCE-MIT, COPYING.txt or NOTICE
+var licenseFilePrefixes = []string{"license", "licence", "copying", "notice", "copyright"}
+
+// ComposerAttribution lists the installed packages with their license texts
+type ComposerAttribution struct {
+	Packages []ComposerAttributionPackage `json:"packages"`
+}
+
+// ComposerAttributionPackage is an installed package with the licenses from composer.lock and the license
+// files found in its directory
+type ComposerAttributionPackage struct {
+	Name         string                    `json:"name"`
+	Version      string                    `json:"version"`
+	License      []string                  `json:"license"`
+	LicenseFiles []ComposerAttributionFile `json:"licenseFiles"`
+}
+
+// ComposerAttributionFile is a license file of a package, with its path relative to the package directory
+type ComposerAttributionFile struct {
+	Path string `json:"path"`
+	Text string `json:"text"`
+}
+
+// attributionEnabled reads BP_COMPOSER_ATTRIBUTION
+func attributionEnabled() (bool, error) {
+	value, found := os.LookupEnv(BpComposerAttribution)
+	if !found || value == "" {
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for %s: '%s', must be a boolean", BpComposerAttribution, value)
+	}
+
+	return enabled, nil
+}
+
+// collectComposerAttribution gathers the license files of the installed packages, as listed with their install
+// paths in vendor/composer/installed.json. This includes builds without a composer.lock. If there is no
+// installed.json, the packages in composer.lock are looked up in the vendor directory, and those that are not
+// installed, such as `require-dev` packages of a `--no-dev` install, are skipped. Installed packages whose
+// directory cannot be found are left out and returned.
+func collectComposerAttribution(composerLock ComposerLock, vendorDir string) (ComposerAttribution, []string, error) {
+	attribution := ComposerAttribution{Packages: []ComposerAttributionPackage{}}
+	var omitted []string
+
+	installed, err := readComposerInstalled(vendorDir)
+	if err != nil {
+		return ComposerAttribution{}, nil, err
+	}
+
+	packages := installed.Packages
+	if len(packages) == 0 {
+		packages = composerLock.AllPackages()
+	}
+
+	for _, p := range packages {
+		packageDir := filepath.Join(vendorDir, filepath.FromSlash(p.Name))
+		if len(installed.Packages) > 0 {
+			// metapackages have no files
+			if p.InstallPath == "" {
+				continue
+			}
+
+			packageDir = filepath.FromSlash(p.InstallPath)
+			if !filepath.IsAbs(packageDir) {
+				packageDir = filepath.Join(vendorDir, "composer", packageDir)
+			}
+		}
+
+		if exists, err := fs.Exists(packageDir); err != nil { // untested
+			return ComposerAttribution{}, nil, err
+		} else if !exists && len(installed.Packages) > 0 {
+			omitted = append(omitted, fmt.Sprintf("%s %s (%s)", p.Name, p.Version, packageDir))
+			continue
+		} else if !exists {
+			continue
+		}
+
+		entries, err := os.ReadDir(packageDir)
+		if err != nil { // untested
+			return ComposerAttribution{}, nil, err
+		}
+
+		attributed := ComposerAttributionPackage{
+			Name:         p.Name,
+			Version:      p.Version,
+			License:      p.License,
+			LicenseFiles: []ComposerAttributionFile{},
+		}
+		if attributed.License == nil {
+			attributed.License = []string{}
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || !isLicenseFile(entry.Name()) {
+				continue
+			}
+
+			content, err := os.ReadFile(filepath.Join(packageDir, entry.Name()))
+			if err != nil { // untested
+				return ComposerAttribution{}, nil, err
+			}
+
+			attributed.LicenseFiles = append(attributed.LicenseFiles, ComposerAttributionFile{
+				Path: entry.Name(),
+				Text: string(content),
+			})
+		}
+
+		attribution.Packages = append(attribution.Packages, attributed)
+	}
+
+	sort.Slice(attribution.Packages, func(i, j int) bool { return attribution.Packages[i].Name < attribution.Packages[j].Name })
+
+	return attribution, omitted, nil
+}
+
+func isLicenseFile(name string) bool {
+	lower := strings.ToLower(name)
+	for _, prefix := range licenseFilePrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Text renders the attribution as a plain text document
+func (a ComposerAttribution) Text() string {
+	separator := strings.Repeat("=", 80)
+
+	buffer := bytes.NewBuffer(nil)
+	buffer.WriteString("Third-party packages installed by Composer\n")
+
+	for _, p := range a.Packages {
+		license := strings.Join(p.License, ", ")
+		if license == "" {
+			license = "(none)"
+		}
+
+		_, _ = fmt.Fprintf(buffer, "\n%s\n%s %s\nLicense: %s\n", separator, p.Name, p.Version, license)
+
+		for _, file := range p.LicenseFiles {
+			_, _ = fmt.Fprintf(buffer, "\n--- %s ---\n\n%s\n", file.Path, strings.TrimRight(file.Text, "\n"))
+		}
+	}
+
+	return buffer.String()
+}
+
+// writeComposerAttribution writes the attribution of the installed packages as attribution.txt and
+// attribution.json into the composer-attribution layer, which is available at launch, and warns about
+// packages without a license file and installed packages that cannot be found
+func writeComposerAttribution(logger scribe.Emitter, context packit.BuildContext, vendorDir string) (packit.Layer, error) {
+	_, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
+	composerLock, err := ParseComposerLock(composerLockPath)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	attribution, omitted, err := collectComposerAttribution(composerLock, vendorDir)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	composerAttributionLayer, err := context.Layers.Get(ComposerAttributionLayerName)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	composerAttributionLayer, err = composerAttributionLayer.Reset()
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+	composerAttributionLayer.Launch = true
+
+	logger.Process("Writing the attribution of %d package(s) to %s", len(attribution.Packages), composerAttributionLayer.Path)
+
+	for _, p := range attribution.Packages {
+		if len(p.LicenseFiles) == 0 {
+			logger.Subprocess("WARNING: no license file found for %s %s", p.Name, p.Version)
+		}
+	}
+
+	if len(omitted) > 0 {
+		logger.Subprocess("WARNING: leaving out %d installed package(s) whose directory does not exist:", len(omitted))
+		for _, name := range omitted {
+			logger.Action("%s", name)
+		}
+	}
+	logger.Break()
+
+	err = os.WriteFile(filepath.Join(composerAttributionLayer.Path, "attribution.txt"), []byte(attribution.Text()), 0644)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	content, err := json.MarshalIndent(attribution, "", "  ")
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	err = os.WriteFile(filepath.Join(composerAttributionLayer.Path, "attribution.json"), content, 0644)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	return composerAttributionLayer, nil
+}
@@ -0,0 +1,475 @@
+package composer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// Values of BP_COMPOSER_AUDIT
+const (
+	ComposerAuditOff  = "off"
+	ComposerAuditWarn = "warn"
+	ComposerAuditFail = "fail"
+)
+
+// composerAdvisorySeverities are the severities of advisories, from lowest to highest
+var composerAdvisorySeverities = []string{"low", "medium", "high", "critical"}
+
+// ComposerAuditPolicy decides what happens with the advisories found by `composer audit`
+type ComposerAuditPolicy struct {
+	// Mode is one of ComposerAuditOff, ComposerAuditWarn or ComposerAuditFail
+	Mode string
+
+	// Severity is the lowest severity of advisories that fail the build
+	Severity string
+
+	// Ignore holds advisory IDs or CVEs that never fail the build
+	Ignore []string
+}
+
+// parseComposerAuditPolicy reads BP_COMPOSER_AUDIT, BP_COMPOSER_AUDIT_SEVERITY and BP_COMPOSER_AUDIT_IGNORE
+func parseComposerAuditPolicy() (ComposerAuditPolicy, error) {
+	policy := ComposerAuditPolicy{
+		Mode:     ComposerAuditOff,
+		Severity: composerAdvisorySeverities[0],
+	}
+
+	if value, found := os.LookupEnv(BpComposerAudit); found && value != "" {
+		policy.Mode = strings.ToLower(strings.TrimSpace(value))
+		switch policy.Mode {
+		case ComposerAuditOff, ComposerAuditWarn, ComposerAuditFail:
+		default:
+			return ComposerAuditPolicy{}, fmt.Errorf("invalid value for %s: '%s', must be one of '%s', '%s' or '%s'",
+				BpComposerAudit, value, ComposerAuditOff, ComposerAuditWarn, ComposerAuditFail)
+		}
+	}
+
+	if value, found := os.LookupEnv(BpComposerAuditSeverity); found && value != "" {
+		policy.Severity = strings.ToLower(strings.TrimSpace(value))
+		if composerAdvisorySeverityRank(policy.Severity) < 0 {
+			return ComposerAuditPolicy{}, fmt.Errorf("invalid value for %s: '%s', must be one of '%s'",
+				BpComposerAuditSeverity, value, strings.Join(composerAdvisorySeverities, "', '"))
+		}
+	}
+
+	policy.Ignore = strings.FieldsFunc(os.Getenv(BpComposerAuditIgnore), func(r rune) bool { return r == ',' || r == ' ' || r == '\n' })
+
+	return policy, nil
+}
+
+// composerAdvisorySeverityRank returns the position of the severity in composerAdvisorySeverities, or -1 if it is unknown
+func composerAdvisorySeverityRank(severity string) int {
+	for i, s := range composerAdvisorySeverities {
+		if s == strings.ToLower(severity) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ignores returns whether the advisory is ignored by any of its IDs
+func (p ComposerAuditPolicy) ignores(advisory ComposerAdvisory) bool {
+	for _, id := range advisory.IDs() {
+		for _, ignored := range p.Ignore {
+			if strings.EqualFold(id, ignored) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fails returns whether the advisory fails the build. Advisories without a known severity always do.
+func (p ComposerAuditPolicy) fails(advisory ComposerAdvisory) bool {
+	if p.Mode != ComposerAuditFail || p.ignores(advisory) {
+		return false
+	}
+
+	rank := composerAdvisorySeverityRank(advisory.Severity)
+	return rank < 0 || rank >= composerAdvisorySeverityRank(p.Severity)
+}
+
+// ComposerAdvisory is a security advisory as reported by `composer audit --format=json`
+type ComposerAdvisory struct {
+	AdvisoryID       string                   `json:"advisoryId"`
+	PackageName      string                   `json:"packageName"`
+	AffectedVersions string                   `json:"affectedVersions"`
+	Title            string                   `json:"title"`
+	CVE              string                   `json:"cve,omitempty"`
+	Link             string                   `json:"link,omitempty"`
+	ReportedAt       string                   `json:"reportedAt,omitempty"`
+	Severity         string                   `json:"severity,omitempty"`
+	Sources          []ComposerAdvisorySource `json:"sources,omitempty"`
+}
+
+// ComposerAdvisorySource is a database that published an advisory, with the ID it has there
+type ComposerAdvisorySource struct {
+	Name     string `json:"name"`
+	RemoteID string `json:"remoteId"`
+}
+
+// IDs returns the distinct advisory ID, CVE and IDs in the sources of the advisory
+func (a ComposerAdvisory) IDs() []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, id := range append([]string{a.AdvisoryID, a.CVE}, a.remoteIDs()...) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (a ComposerAdvisory) remoteIDs() []string {
+	var ids []string
+	for _, source := range a.Sources {
+		ids = append(ids, source.RemoteID)
+	}
+	return ids
+}
+
+// ComposerAuditFinding is an advisory affecting an installed package, as written to the audit report
+type ComposerAuditFinding struct {
+	ComposerAdvisory
+	Version string `json:"version,omitempty"`
+	Ignored bool   `json:"ignored"`
+}
+
+// composerAuditReport is written to the composer-audit layer as audit.json
+type composerAuditReport struct {
+	Mode       string                 `json:"mode"`
+	Severity   string                 `json:"severity"`
+	Advisories []ComposerAuditFinding `json:"advisories"`
+}
+
+// parseComposerAuditOutput parses the output of `composer audit --format=json`. Advisories are grouped
+// by package, either as a list or as an object, and an empty result is encoded as an empty list.
+func parseComposerAuditOutput(content []byte) ([]ComposerAdvisory, error) {
+	var output struct {
+		Advisories json.RawMessage `json:"advisories"`
+	}
+	err := json.Unmarshal(content, &output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the output of composer audit: %w", err)
+	}
+
+	if output.Advisories == nil {
+		return nil, fmt.Errorf("failed to parse the output of composer audit: no advisories found in %q", content)
+	}
+
+	var packages map[string]json.RawMessage
+	if err := json.Unmarshal(output.Advisories, &packages); err != nil {
+		var empty []json.RawMessage
+		if json.Unmarshal(output.Advisories, &empty) == nil && len(empty) == 0 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to parse the output of composer audit: %w", err)
+	}
+
+	var advisories []ComposerAdvisory
+	for name, raw := range packages {
+		var list []ComposerAdvisory
+		if err := json.Unmarshal(raw, &list); err != nil {
+			var indexed map[string]ComposerAdvisory
+			if err := json.Unmarshal(raw, &indexed); err != nil {
+				return nil, fmt.Errorf("failed to parse the advisories of %s: %w", name, err)
+			}
+			for _, advisory := range indexed {
+				list = append(list, advisory)
+			}
+		}
+
+		for _, advisory := range list {
+			if advisory.PackageName == "" {
+				advisory.PackageName = name
+			}
+			advisories = append(advisories, advisory)
+		}
+	}
+
+	sort.Slice(advisories, func(i, j int) bool {
+		if advisories[i].PackageName != advisories[j].PackageName {
+			return advisories[i].PackageName < advisories[j].PackageName
+		}
+		return advisories[i].AdvisoryID < advisories[j].AdvisoryID
+	})
+
+	return advisories, nil
+}
+
+// runComposerAudit checks the packages in the composer.lock of the application for security advisories,
+// and writes the findings to the build-only composer-audit layer: as audit.json, and as the vulnerabilities
+// of a CycloneDX SBOM when that format is requested. Depending on the policy, advisories fail the build.
+//
+// The advisories come from the given advisory database, or else from `composer audit`. Since it exits with
+// a non-zero code when it finds advisories, its output is parsed regardless. When the packages were installed
+// with `--no-dev`, the `require-dev` packages are not in the image and are not checked.
+//
+// https://getcomposer.org/doc/03-cli.md#audit
+func runComposerAudit(
+	logger scribe.Emitter,
+	context packit.BuildContext,
+	composerAuditExec Executable,
+	database *ComposerAdvisoryDatabase,
+	policy ComposerAuditPolicy,
+	composerPhpIniPath string,
+	composerHome string,
+	path string,
+	noDev bool,
+	settings composerSettings) (packit.Layer, error) {
+
+	composerJsonPath, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
+
+	var advisories []ComposerAdvisory
+	var err error
+	if database != nil {
+		logger.Process("Checking the locked packages against the advisory database")
+		advisories, err = database.Audit(logger, composerLockPath, noDev)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+	} else {
+		args := []string{"audit", "--locked", "--format=json"}
+		if noDev {
+			args = append(args, "--no-dev")
+		}
+		// in hardened mode, the plugins of the application do not run
+		if settings.hardened != nil {
+			args = append(args, "--no-plugins")
+		}
+		logger.Process("Running 'composer %s'", strings.Join(args, " "))
+
+		stdout := bytes.NewBuffer(nil)
+		execution := pexec.Execution{
+			Args: args,
+			Dir:  context.WorkingDir,
+			Env: settings.environ(logger,
+				"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
+				fmt.Sprintf("COMPOSER=%s", composerJsonPath),
+				fmt.Sprintf("COMPOSER_HOME=%s", composerHome),
+				fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
+				fmt.Sprintf("PATH=%s", path),
+			),
+			Stdout: stdout,
+			Stderr: logger.ActionWriter,
+		}
+
+		execErr := composerAuditExec.Execute(execution)
+		flushActionWriter(logger)
+
+		advisories, err = parseComposerAuditOutput(stdout.Bytes())
+		if err != nil {
+			if execErr != nil {
+				return packit.Layer{}, fmt.Errorf("failed to run composer audit: %w", execErr)
+			}
+			return packit.Layer{}, err
+		}
+	}
+
+	lock, err := ParseComposerLock(composerLockPath)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	versions := map[string]string{}
+	for _, p := range lock.AllPackages() {
+		versions[strings.ToLower(p.Name)] = p.Version
+	}
+
+	report := composerAuditReport{
+		Mode:       policy.Mode,
+		Severity:   policy.Severity,
+		Advisories: []ComposerAuditFinding{},
+	}
+
+	var failing []string
+	for _, advisory := range advisories {
+		finding := ComposerAuditFinding{
+			ComposerAdvisory: advisory,
+			Version:          versions[strings.ToLower(advisory.PackageName)],
+			Ignored:          policy.ignores(advisory),
+		}
+		report.Advisories = append(report.Advisories, finding)
+
+		severity := advisory.Severity
+		if severity == "" {
+			severity = "unknown severity"
+		}
+
+		status := ""
+		if finding.Ignored {
+			status = " (ignored)"
+		}
+
+		logger.Subprocess("%s %s: %s [%s, %s]%s", finding.PackageName, finding.Version, advisory.Title, strings.Join(advisory.IDs(), ", "), severity, status)
+
+		if policy.fails(advisory) {
+			failing = append(failing, fmt.Sprintf("%s (%s)", advisory.PackageName, strings.Join(advisory.IDs(), ", ")))
+		}
+	}
+
+	if len(advisories) == 0 {
+		logger.Subprocess("No security advisories found")
+	}
+	logger.Break()
+
+	composerAuditLayer, err := context.Layers.Get(ComposerAuditLayerName)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	composerAuditLayer, err = composerAuditLayer.Reset()
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+	composerAuditLayer.Build = true
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	err = os.WriteFile(filepath.Join(composerAuditLayer.Path, "audit.json"), content, 0644)
+	if err != nil { // untested
+		return packit.Layer{}, err
+	}
+
+	for _, format := range context.BuildpackInfo.SBOMFormats {
+		if strings.HasPrefix(format, sbom.CycloneDXFormat) {
+			content, err := composerAuditCycloneDX(report.Advisories)
+			if err != nil { // untested
+				return packit.Layer{}, err
+			}
+
+			composerAuditLayer.SBOM = packit.SBOMFormats{{
+				Extension: sbom.Format(sbom.CycloneDXFormat).Extension(),
+				Content:   bytes.NewReader(content),
+			}}
+		}
+	}
+
+	unignored := 0
+	for _, finding := range report.Advisories {
+		if !finding.Ignored {
+			unignored++
+		}
+	}
+
+	if len(failing) > 0 {
+		return packit.Layer{}, fmt.Errorf("composer audit found %d advisories at or above severity '%s':\n  %s", len(failing), policy.Severity, strings.Join(failing, "\n  "))
+	} else if unignored > 0 {
+		logger.Process("WARNING: composer audit found %d security advisories, see %s", unignored, filepath.Join(composerAuditLayer.Path, "audit.json"))
+		logger.Break()
+	}
+
+	return composerAuditLayer, nil
+}
+
+// composerAuditCycloneDX returns a CycloneDX document with the affected packages as components and the
+// findings as their vulnerabilities
+//
+// https://cyclonedx.org/docs/1.4/json/#vulnerabilities
+func composerAuditCycloneDX(findings []ComposerAuditFinding) ([]byte, error) {
+	components := []interface{}{}
+	vulnerabilities := []interface{}{}
+
+	refs := map[string]bool{}
+	for _, finding := range findings {
+		ref := composerPackageURL(finding.PackageName, finding.Version)
+
+		if !refs[ref] {
+			refs[ref] = true
+
+			group, name, _ := strings.Cut(finding.PackageName, "/")
+			component := map[string]interface{}{
+				"bom-ref": ref,
+				"type":    "library",
+				"group":   group,
+				"name":    name,
+				"purl":    ref,
+			}
+			if finding.Version != "" {
+				component["version"] = finding.Version
+			}
+			components = append(components, component)
+		}
+
+		id := finding.CVE
+		if id == "" {
+			id = finding.AdvisoryID
+		}
+
+		vulnerability := map[string]interface{}{
+			"id":          id,
+			"description": finding.Title,
+			"affects":     []interface{}{map[string]interface{}{"ref": ref}},
+		}
+
+		source := map[string]interface{}{}
+		if len(finding.Sources) > 0 {
+			source["name"] = finding.Sources[0].Name
+		}
+		if finding.Link != "" {
+			source["url"] = finding.Link
+			vulnerability["advisories"] = []interface{}{map[string]interface{}{"url": finding.Link}}
+		}
+		if len(source) > 0 {
+			vulnerability["source"] = source
+		}
+
+		var references []interface{}
+		for _, otherID := range finding.IDs() {
+			if otherID != id {
+				references = append(references, map[string]interface{}{"id": otherID})
+			}
+		}
+		if len(references) > 0 {
+			vulnerability["references"] = references
+		}
+
+		if finding.Severity != "" {
+			vulnerability["ratings"] = []interface{}{map[string]interface{}{"severity": strings.ToLower(finding.Severity)}}
+		}
+
+		if reportedAt, err := time.Parse(time.RFC3339, finding.ReportedAt); err == nil {
+			vulnerability["published"] = reportedAt.UTC().Format(time.RFC3339)
+		}
+
+		if finding.Ignored {
+			vulnerability["analysis"] = map[string]interface{}{"detail": fmt.Sprintf("ignored with %s", BpComposerAuditIgnore)}
+		}
+
+		vulnerabilities = append(vulnerabilities, vulnerability)
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"bomFormat":       "CycloneDX",
+		"specVersion":     "1.4",
+		"version":         1,
+		"components":      components,
+		"vulnerabilities": vulnerabilities,
+	}, "", "  ")
+}
+
+// composerPackageURL returns the package URL of a Composer package
+//
+// https://github.com/package-url/purl-spec/blob/master/PURL-TYPES.rst#composer
+func composerPackageURL(name, version string) string {
+	purl := fmt.Sprintf("pkg:composer/%s", strings.ToLower(name))
+	if version != "" {
+		purl = fmt.Sprintf("%s@%s", purl, version)
+	}
+	return purl
+}
@@ -0,0 +1,164 @@
+package composer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
+)
+
+// composerAuthBindingTypes are the service binding types that configure Composer authentication
+var composerAuthBindingTypes = []string{"composer", "composer-auth"}
+
+// composerAuthKeys are the sections of Composer's auth.json that can be set from a binding
+// https://getcomposer.org/doc/articles/authentication-for-private-packages.md
+var composerAuthKeys = []string{"github-oauth", "gitlab-token", "gitlab-oauth", "bitbucket-oauth", "http-basic", "bearer"}
+
+// setupComposerAuth returns the credentials from `composer` and `composer-auth` service bindings
+// as the COMPOSER_AUTH environment of every Composer invocation. They are never written to a layer,
+// so they are neither cached nor available at launch. An auth.json is not written either, since
+// Composer only reads it from COMPOSER_HOME, which is inside the cached layers.
+//
+// Each binding can provide an entry per section of auth.json, e.g. an entry named `github-oauth`
+// containing `{"github.com": "token"}`, and/or a complete `auth.json` entry. Credentials from the
+// bindings are merged over those from COMPOSER_AUTH, if it is set.
+func setupComposerAuth(logger scribe.Emitter, context packit.BuildContext, bindingResolver BindingResolver) ([]string, error) {
+	var bindings []servicebindings.Binding
+	for _, bindingType := range composerAuthBindingTypes {
+		resolved, err := bindingResolver.Resolve(bindingType, "", context.Platform.Path)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, resolved...)
+	}
+
+	if len(bindings) == 0 {
+		return nil, nil
+	}
+
+	auth := map[string]map[string]interface{}{}
+
+	if value, found := os.LookupEnv("COMPOSER_AUTH"); found && value != "" {
+		err := mergeComposerAuth(auth, []byte(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid COMPOSER_AUTH: %w", err)
+		}
+	}
+
+	for _, binding := range bindings {
+		logger.Process("Configuring Composer authentication from service binding '%s'", binding.Name)
+
+		if entry, ok := binding.Entries["auth.json"]; ok {
+			content, err := entry.ReadBytes()
+			if err != nil { // untested
+				return nil, err
+			}
+
+			err = mergeComposerAuth(auth, content)
+			if err != nil {
+				return nil, fmt.Errorf("invalid entry 'auth.json' in binding '%s': %w", binding.Name, err)
+			}
+		}
+
+		for _, key := range composerAuthKeys {
+			entry, ok := binding.Entries[key]
+			if !ok {
+				continue
+			}
+
+			content, err := entry.ReadBytes()
+			if err != nil { // untested
+				return nil, err
+			}
+
+			var section map[string]interface{}
+			err = json.Unmarshal(content, &section)
+			if err != nil {
+				return nil, fmt.Errorf("invalid entry '%s' in binding '%s': must be a JSON object mapping hosts to credentials", key, binding.Name)
+			}
+
+			if auth[key] == nil {
+				auth[key] = map[string]interface{}{}
+			}
+			for host, credentials := range section {
+				auth[key][host] = credentials
+			}
+		}
+	}
+
+	var sections []string
+	for key, section := range auth {
+		for host := range section {
+			sections = append(sections, fmt.Sprintf("%s: %s", key, host))
+		}
+	}
+	sort.Strings(sections)
+	logger.Debug.Subprocess("Credentials configured for %v", sections)
+
+	content, err := json.Marshal(auth)
+	if err != nil { // untested
+		return nil, err
+	}
+
+	return []string{fmt.Sprintf("COMPOSER_AUTH=%s", content)}, nil
+}
+
+// mergeComposerAuth merges the sections of an auth.json document into auth, by host
+func mergeComposerAuth(auth map[string]map[string]interface{}, content []byte) error {
+	var document map[string]map[string]interface{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return fmt.Errorf("must be a JSON object of auth.json sections")
+	}
+
+	for key, section := range document {
+		if auth[key] == nil {
+			auth[key] = map[string]interface{}{}
+		}
+		for host, credentials := range section {
+			auth[key][host] = credentials
+		}
+	}
+
+	return nil
+}
+
+// composerAuthSecrets returns the credentials in the COMPOSER_AUTH entries of env,
+// so that they can be masked in Composer's output. Usernames are not considered secret.
+func composerAuthSecrets(env []string) []string {
+	var secrets []string
+	for _, variable := range env {
+		value, found := strings.CutPrefix(variable, "COMPOSER_AUTH=")
+		if !found {
+			continue
+		}
+
+		var auth map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &auth); err != nil {
+			continue
+		}
+		secrets = append(secrets, stringValues(auth)...)
+	}
+
+	return secrets
+}
+
+func stringValues(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case map[string]interface{}:
+		var values []string
+		for key, item := range v {
+			if key != "username" {
+				values = append(values, stringValues(item)...)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package composer
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// caCertificatesBindingType is the service binding type whose entries are PEM encoded CA certificates,
+// as also used by the Paketo CA Certificates Buildpack
+const caCertificatesBindingType = "ca-certificates"
+
+// systemCABundles are the locations of the system CA bundle on common distributions
+var systemCABundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/cert.pem",
+}
+
+// setupComposerCACertificates combines the system CA bundle with the certificates from
+// `ca-certificates` service bindings and BP_COMPOSER_CA_CERTS into a bundle in the
+// composer-ca-certificates layer, and returns its path. The layer is not returned from Build.
+//
+// It returns an empty path when no additional certificates are configured.
+func setupComposerCACertificates(logger scribe.Emitter, context packit.BuildContext, bindingResolver BindingResolver) (string, error) {
+	var certificates [][]byte
+
+	bindings, err := bindingResolver.Resolve(caCertificatesBindingType, "", context.Platform.Path)
+	if err != nil {
+		return "", err
+	}
+
+	for _, binding := range bindings {
+		logger.Process("Adding CA certificates from service binding '%s'", binding.Name)
+
+		var names []string
+		for name := range binding.Entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			content, err := binding.Entries[name].ReadBytes()
+			if err != nil { // untested
+				return "", err
+			}
+
+			if !containsCertificate(content) {
+				return "", fmt.Errorf("entry '%s' in binding '%s' does not contain a PEM encoded certificate", name, binding.Name)
+			}
+			certificates = append(certificates, content)
+		}
+	}
+
+	for _, path := range filepath.SplitList(os.Getenv(BpComposerCACerts)) {
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(context.WorkingDir, path)
+		}
+
+		logger.Process("Adding CA certificates from %s", path)
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read CA certificates from %s: %w", BpComposerCACerts, err)
+		}
+
+		if !containsCertificate(content) {
+			return "", fmt.Errorf("%s does not contain a PEM encoded certificate", path)
+		}
+		certificates = append(certificates, content)
+	}
+
+	if len(certificates) == 0 {
+		return "", nil
+	}
+
+	systemBundle, err := findSystemCABundle()
+	if err != nil { // untested
+		return "", err
+	}
+
+	if systemBundle != "" {
+		logger.Debug.Subprocess("Including the system CA bundle %s", systemBundle)
+
+		content, err := os.ReadFile(systemBundle)
+		if err != nil { // untested
+			return "", err
+		}
+		certificates = append([][]byte{content}, certificates...)
+	} else {
+		logger.Process("WARNING: no system CA bundle found, only the configured CA certificates are trusted")
+	}
+
+	caCertificatesLayer, err := context.Layers.Get(ComposerCACertificatesLayerName)
+	if err != nil { // untested
+		return "", err
+	}
+
+	caCertificatesLayer, err = caCertificatesLayer.Reset()
+	if err != nil { // untested
+		return "", err
+	}
+
+	var bundle []byte
+	for _, content := range certificates {
+		bundle = append(bundle, bytes.TrimRight(content, "\n")...)
+		bundle = append(bundle, '\n')
+	}
+
+	bundlePath := filepath.Join(caCertificatesLayer.Path, "ca-bundle.crt")
+	err = os.WriteFile(bundlePath, bundle, 0644)
+	if err != nil { // untested
+		return "", err
+	}
+
+	return bundlePath, nil
+}
+
+// findSystemCABundle returns the CA bundle from SSL_CERT_FILE, or else the first existing systemCABundles
+func findSystemCABundle() (string, error) {
+	candidates := systemCABundles
+	if value, found := os.LookupEnv("SSL_CERT_FILE"); found && value != "" {
+		candidates = []string{value}
+	}
+
+	for _, candidate := range candidates {
+		if exists, err := fs.Exists(candidate); err != nil {
+			return "", err
+		} else if exists {
+			return candidate, nil
+		}
+	}
+
+	return "", nil
+}
+
+func containsCertificate(content []byte) bool {
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			return false
+		}
+		if block.Type == "CERTIFICATE" {
+			return true
+		}
+	}
+}
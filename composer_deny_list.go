@@ -0,0 +1,133 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// composerDenyListBindingType is the service binding type that provides a deny list of packages
+const composerDenyListBindingType = "composer-deny-list"
+
+// composerDenyListEntry is the binding entry holding the deny list, with a rule per line
+const composerDenyListEntry = "deny-list"
+
+// ComposerDenyRule denies a package, or only the versions of it that match a constraint
+type ComposerDenyRule struct {
+	// Rule is the rule as written, e.g. `vendor/package:>=1.2,<1.2.5`
+	Rule string
+
+	// Name of the denied package, where `*` matches any part of a name
+	Name string
+
+	// Constraint is nil when every version is denied
+	Constraint *ComposerVersionConstraint
+
+	// Origin is where the rule was configured
+	Origin string
+}
+
+// parseComposerDenyRules parses rules of the form `vendor/package` or `vendor/package:constraint`,
+// separated by newlines or `;`. Empty lines and lines starting with `#` are skipped.
+func parseComposerDenyRules(value, origin string) ([]ComposerDenyRule, error) {
+	var rules []ComposerDenyRule
+	for _, line := range strings.FieldsFunc(value, func(r rune) bool { return r == '\n' || r == ';' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, constraint, found := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !strings.Contains(name, "/") || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid rule '%s' in %s: must be of the form 'vendor/package' or 'vendor/package:constraint'", line, origin)
+		}
+
+		rule := ComposerDenyRule{Rule: line, Name: name, Origin: origin}
+		if found {
+			parsed, err := ParseComposerVersionConstraint(constraint)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rule '%s' in %s: %w", line, origin, err)
+			}
+			rule.Constraint = &parsed
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// resolveComposerDenyList reads the rules from BP_COMPOSER_DENY and from the `deny-list` entry of
+// `composer-deny-list` service bindings
+func resolveComposerDenyList(context packit.BuildContext, bindingResolver BindingResolver) ([]ComposerDenyRule, error) {
+	rules, err := parseComposerDenyRules(os.Getenv(BpComposerDeny), BpComposerDeny)
+	if err != nil {
+		return nil, err
+	}
+
+	bindings, err := bindingResolver.Resolve(composerDenyListBindingType, "", context.Platform.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, binding := range bindings {
+		entry, ok := binding.Entries[composerDenyListEntry]
+		if !ok {
+			return nil, fmt.Errorf("binding '%s' of type '%s' is missing the '%s' entry", binding.Name, composerDenyListBindingType, composerDenyListEntry)
+		}
+
+		content, err := entry.ReadString()
+		if err != nil { // untested
+			return nil, err
+		}
+
+		bindingRules, err := parseComposerDenyRules(content, fmt.Sprintf("service binding '%s'", binding.Name))
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, bindingRules...)
+	}
+
+	return rules, nil
+}
+
+// Matches returns whether the rule denies the given version of a package
+func (r ComposerDenyRule) Matches(name, version string) bool {
+	if !matchesPattern(r.Name, name) {
+		return false
+	}
+
+	return r.Constraint == nil || r.Constraint.Matches(version)
+}
+
+// checkDeniedPackages fails when any of the packages is denied, naming each package with the rule it hit
+func checkDeniedPackages(logger scribe.Emitter, rules []ComposerDenyRule, packages []ComposerLockPackage, source string) error {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	logger.Process("Checking %d package(s) in %s against %d deny rule(s)", len(packages), source, len(rules))
+
+	var denied []string
+	for _, p := range packages {
+		for _, rule := range rules {
+			if rule.Matches(p.Name, p.Version) {
+				denied = append(denied, fmt.Sprintf("%s %s is denied by rule '%s' from %s", p.Name, p.Version, rule.Rule, rule.Origin))
+				break
+			}
+		}
+	}
+
+	if len(denied) > 0 {
+		return fmt.Errorf("found %d denied package(s) in %s:\n  %s", len(denied), source, strings.Join(denied, "\n  "))
+	}
+
+	logger.Subprocess("No denied packages found")
+	logger.Break()
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package composer
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// composerDependencyMappingBindingType is the service binding type that maps locked packages to local archives
+const composerDependencyMappingBindingType = "composer-dependency-mapping"
+
+// composerDependencyMappingsEntry is the binding entry holding the mappings
+const composerDependencyMappingsEntry = "mappings.json"
+
+// ComposerDependencyMappings maps the dist URL of a locked package, or its `name@reference`,
+// to the path of a local archive
+type ComposerDependencyMappings map[string]string
+
+// Archive returns the local archive for a locked package
+func (m ComposerDependencyMappings) Archive(name, reference, distURL string) (string, bool) {
+	if archive, ok := m[distURL]; ok && distURL != "" {
+		return archive, true
+	}
+
+	if archive, ok := m[fmt.Sprintf("%s@%s", name, reference)]; ok && reference != "" {
+		return archive, true
+	}
+
+	return "", false
+}
+
+// resolveComposerDependencyMappings reads the `mappings.json` entry of `composer-dependency-mapping`
+// service bindings. Archive paths are relative to the binding, so the archives can be provided as
+// further entries of the same binding. It returns nil when there is no such binding.
+func resolveComposerDependencyMappings(logger scribe.Emitter, context packit.BuildContext, bindingResolver BindingResolver) (ComposerDependencyMappings, error) {
+	bindings, err := bindingResolver.Resolve(composerDependencyMappingBindingType, "", context.Platform.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bindings) == 0 {
+		return nil, nil
+	}
+
+	mappings := ComposerDependencyMappings{}
+	for _, binding := range bindings {
+		logger.Process("Installing packages offline using the dependency mappings from service binding '%s'", binding.Name)
+
+		entry, ok := binding.Entries[composerDependencyMappingsEntry]
+		if !ok {
+			return nil, fmt.Errorf("binding '%s' of type '%s' is missing the '%s' entry", binding.Name, composerDependencyMappingBindingType, composerDependencyMappingsEntry)
+		}
+
+		content, err := entry.ReadBytes()
+		if err != nil { // untested
+			return nil, err
+		}
+
+		var bindingMappings map[string]string
+		err = json.Unmarshal(content, &bindingMappings)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry '%s' in binding '%s': must be a JSON object mapping dist URLs or 'name@reference' to archives: %w", composerDependencyMappingsEntry, binding.Name, err)
+		}
+
+		for key, archive := range bindingMappings {
+			if !filepath.IsAbs(archive) {
+				archive = filepath.Join(binding.Path, archive)
+			}
+
+			if exists, err := fs.Exists(archive); err != nil { // untested
+				return nil, err
+			} else if !exists {
+				return nil, fmt.Errorf("archive %s mapped for '%s' in binding '%s' does not exist", archive, key, binding.Name)
+			}
+
+			mappings[key] = archive
+		}
+	}
+
+	return mappings, nil
+}
@@ -0,0 +1,109 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// composerBaseEnvironment are the variables of the build environment that Composer always receives in
+// hermetic mode, where `*` matches any part of a name. They cover the user, locale, temporary directory
+// and proxies, the variables that the PHP layers export for PHP to find its libraries, extensions and
+// configuration, and the Composer variables that can be used to configure Composer.
+var composerBaseEnvironment = []string{
+	"PATH",
+	"HOME",
+	"USER",
+	"LANG",
+	"LANGUAGE",
+	"LC_*",
+	"TZ",
+	"TMPDIR",
+	"TERM",
+	"HTTP_PROXY",
+	"HTTPS_PROXY",
+	"NO_PROXY",
+	"http_proxy",
+	"https_proxy",
+	"no_proxy",
+	"SSL_CERT_DIR",
+	"LD_LIBRARY_PATH",
+	"LIBRARY_PATH",
+	"PHP_HOME",
+	"PHP_API",
+	"PHP_EXTENSION_DIR",
+	"PHP_INI_SCAN_DIR",
+	"MIBDIRS",
+	"COMPOSER_*",
+}
+
+// parseComposerEnvironment reads BP_COMPOSER_HERMETIC_ENV, and the variables that are allowed in addition
+// to the base environment from BP_COMPOSER_ENV_ALLOWLIST
+func parseComposerEnvironment() (bool, []string, error) {
+	value, found := os.LookupEnv(BpComposerHermeticEnv)
+	if !found || value == "" {
+		return false, nil, nil
+	}
+
+	hermetic, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, nil, fmt.Errorf("invalid value for %s: '%s', must be a boolean", BpComposerHermeticEnv, value)
+	}
+
+	if !hermetic {
+		return false, nil, nil
+	}
+
+	allowlist := append([]string{}, composerBaseEnvironment...)
+	allowlist = append(allowlist, strings.FieldsFunc(os.Getenv(BpComposerEnvAllowlist), func(r rune) bool { return r == ',' || r == ' ' || r == '\n' })...)
+
+	for _, pattern := range allowlist {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return false, nil, fmt.Errorf("invalid pattern '%s' in %s: %w", pattern, BpComposerEnvAllowlist, err)
+		}
+	}
+
+	return true, allowlist, nil
+}
+
+// environ returns the environment of a Composer execution: the environment of the build, followed by the
+// variables controlled by the buildpack and the given variables. In hermetic mode, only the variables of the
+// build environment that are on the allowlist are passed, and the names of all variables are logged.
+func (s composerSettings) environ(logger scribe.Emitter, variables ...string) []string {
+	if !s.hermetic {
+		return append(append(os.Environ(), s.env...), variables...)
+	}
+
+	var env []string
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		for _, pattern := range s.envAllowlist {
+			if matched, _ := path.Match(pattern, name); matched {
+				env = append(env, variable)
+				break
+			}
+		}
+	}
+	env = append(append(env, s.env...), variables...)
+
+	names := map[string]bool{}
+	for _, variable := range env {
+		name, _, _ := strings.Cut(variable, "=")
+		names[name] = true
+	}
+
+	var keys []string
+	for name := range names {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+
+	logger.Debug.Subprocess("Environment: %s", strings.Join(keys, ", "))
+
+	return env
+}
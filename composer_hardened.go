@@ -0,0 +1,260 @@
+package composer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// composerInstallScriptEvents are the script events dispatched by `composer install` that `composer run-script`
+// can dispatch again, in the order `composer install` dispatches them
+//
+// https://getcomposer.org/doc/articles/scripts.md#command-events
+var composerInstallScriptEvents = []string{"pre-install-cmd", "pre-autoload-dump", "post-autoload-dump", "post-install-cmd"}
+
+// composerPackageScriptEvents are dispatched for single packages during `composer install`, and can never
+// be run in hardened mode
+//
+// https://getcomposer.org/doc/articles/scripts.md#installer-events
+var composerPackageScriptEvents = []string{"pre-operations-exec", "pre-package-install", "post-package-install"}
+
+// ComposerHardenedPolicy lists the plugins and script events that may run in hardened mode
+type ComposerHardenedPolicy struct {
+	// AllPlugins is set when composer.json sets `config.allow-plugins` to true
+	AllPlugins bool
+
+	// Plugins are the names of the allowed plugins, where `*` matches any part of a name
+	Plugins []string
+
+	// Scripts are the allowed script events
+	Scripts []string
+}
+
+// hardenedModeEnabled reads BP_COMPOSER_HARDENED
+func hardenedModeEnabled() (bool, error) {
+	value, found := os.LookupEnv(BpComposerHardened)
+	if !found || value == "" {
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for %s: '%s', must be a boolean", BpComposerHardened, value)
+	}
+
+	return enabled, nil
+}
+
+// resolveComposerHardenedPolicy allows the plugins from `config.allow-plugins` in composer.json and
+// BP_COMPOSER_ALLOWED_PLUGINS, and the script events from BP_COMPOSER_ALLOWED_SCRIPTS
+func resolveComposerHardenedPolicy(composerJsonPath string) (ComposerHardenedPolicy, error) {
+	var policy ComposerHardenedPolicy
+
+	composerJson, err := readComposerJson(composerJsonPath)
+	if err != nil {
+		return ComposerHardenedPolicy{}, err
+	}
+
+	config, _ := composerJson["config"].(map[string]interface{})
+	switch allowPlugins := config["allow-plugins"].(type) {
+	case bool:
+		policy.AllPlugins = allowPlugins
+	case map[string]interface{}:
+		for name, allowed := range allowPlugins {
+			if allowed, _ := allowed.(bool); allowed {
+				policy.Plugins = append(policy.Plugins, name)
+			}
+		}
+		sort.Strings(policy.Plugins)
+	}
+
+	split := func(value string) []string {
+		return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' })
+	}
+
+	policy.Plugins = append(policy.Plugins, split(os.Getenv(BpComposerAllowedPlugins))...)
+
+	for _, event := range split(os.Getenv(BpComposerAllowedScripts)) {
+		if !slices.Contains(composerInstallScriptEvents, event) {
+			return ComposerHardenedPolicy{}, fmt.Errorf("invalid script event '%s' in %s, must be one of: %s",
+				event, BpComposerAllowedScripts, strings.Join(composerInstallScriptEvents, ", "))
+		}
+		policy.Scripts = append(policy.Scripts, event)
+	}
+
+	return policy, nil
+}
+
+// allowsPlugin returns whether the plugin may run
+func (p ComposerHardenedPolicy) allowsPlugin(name string) bool {
+	if p.AllPlugins {
+		return true
+	}
+
+	for _, pattern := range p.Plugins {
+		if matchesPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hardenInstallArgs adds `--no-plugins --no-scripts` to the arguments of `composer install`
+func hardenInstallArgs(args []string) []string {
+	args = appendOption(args, "--no-plugins")
+	return appendOption(args, "--no-scripts")
+}
+
+// readComposerJson reads composer.json as a generic document, so that it can be written back unchanged
+func readComposerJson(composerJsonPath string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(composerJsonPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var composerJson map[string]interface{}
+	err = json.Unmarshal(content, &composerJson)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(composerJsonPath), err)
+	}
+
+	if composerJson == nil {
+		composerJson = map[string]interface{}{}
+	}
+
+	return composerJson, nil
+}
+
+// runComposerHardenedScripts runs the allowed plugins and script events after `composer install` ran with
+// `--no-plugins --no-scripts`, and logs everything it skips. It writes a copy of composer.json with
+// `config.allow-plugins` set for each installed plugin and without the scripts of events that are not allowed,
+// and dispatches the install events again with `composer run-script`.
+//
+// The install arguments, before hardening, decide whether `--no-dev` is passed, and whether plugins or
+// scripts were disabled explicitly, in which case they are not run at all.
+func runComposerHardenedScripts(
+	logger scribe.Emitter,
+	context packit.BuildContext,
+	composerRunScriptExec Executable,
+	policy ComposerHardenedPolicy,
+	composerJsonPath string,
+	installArgs []string,
+	env []string,
+	workspaceVendorDir string) error {
+
+	logger.Process("Running in hardened mode")
+
+	installed, err := readComposerInstalled(workspaceVendorDir)
+	if err != nil {
+		return err
+	}
+
+	pluginsDisabled := slices.Contains(installArgs, "--no-plugins")
+	scriptsDisabled := slices.Contains(installArgs, "--no-scripts")
+
+	allowPlugins := map[string]interface{}{}
+	var allowedPlugins []string
+	for _, p := range installed.Packages {
+		if p.Type != "composer-plugin" {
+			continue
+		}
+
+		allowed := !pluginsDisabled && policy.allowsPlugin(p.Name)
+		allowPlugins[p.Name] = allowed
+		if allowed {
+			allowedPlugins = append(allowedPlugins, p.Name)
+			logger.Subprocess("Allowing plugin %s", p.Name)
+		} else {
+			logger.Subprocess("Skipping plugin %s", p.Name)
+		}
+	}
+
+	composerJson, err := readComposerJson(composerJsonPath)
+	if err != nil {
+		return err
+	}
+
+	scripts, _ := composerJson["scripts"].(map[string]interface{})
+	var events []string
+	for _, event := range append(append([]string{}, composerInstallScriptEvents...), composerPackageScriptEvents...) {
+		_, defined := scripts[event]
+		allowed := !scriptsDisabled && slices.Contains(policy.Scripts, event)
+
+		if defined && allowed {
+			logger.Subprocess("Allowing scripts for %s", event)
+		} else if defined {
+			logger.Subprocess("Skipping scripts for %s", event)
+			delete(scripts, event)
+		}
+
+		if slices.Contains(composerInstallScriptEvents, event) && ((defined && allowed) || len(allowedPlugins) > 0) {
+			events = append(events, event)
+		}
+	}
+	logger.Break()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	config, _ := composerJson["config"].(map[string]interface{})
+	if config == nil {
+		config = map[string]interface{}{}
+		composerJson["config"] = config
+	}
+	config["allow-plugins"] = allowPlugins
+
+	composerHardenedLayer, err := context.Layers.Get(ComposerHardenedLayerName)
+	if err != nil { // untested
+		return err
+	}
+
+	composerHardenedLayer, err = composerHardenedLayer.Reset()
+	if err != nil { // untested
+		return err
+	}
+
+	content, err := json.MarshalIndent(composerJson, "", "    ")
+	if err != nil { // untested
+		return err
+	}
+
+	hardenedComposerJsonPath := filepath.Join(composerHardenedLayer.Path, filepath.Base(composerJsonPath))
+	err = os.WriteFile(hardenedComposerJsonPath, content, 0644)
+	if err != nil { // untested
+		return err
+	}
+
+	for _, event := range events {
+		args := []string{"run-script", event}
+		if slices.Contains(installArgs, "--no-dev") {
+			args = append(args, "--no-dev")
+		}
+		logger.Process("Running 'composer %s'", strings.Join(args, " "))
+
+		execution := pexec.Execution{
+			Args:   args,
+			Dir:    context.WorkingDir,
+			Env:    append(append([]string{}, env...), fmt.Sprintf("COMPOSER=%s", hardenedComposerJsonPath)),
+			Stdout: logger.ActionWriter,
+			Stderr: logger.ActionWriter,
+		}
+		err = composerRunScriptExec.Execute(execution)
+		flushActionWriter(logger)
+		if err != nil {
+			return err
+		}
+	}
+	logger.Break()
+
+	return nil
+}
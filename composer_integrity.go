@@ -0,0 +1,168 @@
+package composer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// distVerificationEnabled reads BP_COMPOSER_VERIFY_DIST
+func distVerificationEnabled() (bool, error) {
+	value, found := os.LookupEnv(BpComposerVerifyDist)
+	if !found || value == "" {
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for %s: '%s', must be a boolean", BpComposerVerifyDist, value)
+	}
+
+	return enabled, nil
+}
+
+// composerCacheArchivePath returns where Composer caches the archive of a package, which is named after
+// the SHA-1 of the URL it was downloaded from
+//
+// https://github.com/composer/composer/blob/main/src/Composer/Downloader/FileDownloader.php
+func composerCacheArchivePath(cacheDir string, p ComposerLockPackage, distURL string) string {
+	key := sha1.Sum([]byte(distURL))
+	return filepath.Join(cacheDir, "files", filepath.FromSlash(strings.ToLower(p.Name)), fmt.Sprintf("%s.%s", hex.EncodeToString(key[:]), p.Dist.Type))
+}
+
+func sha1File(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil { // untested
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha1.New()
+	_, err = io.Copy(hash, file)
+	if err != nil { // untested
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// verifyComposerIntegrity checks the archives of the locked packages in the Composer cache against their
+// dist shasum in composer.lock, and that vendor/composer/installed.json lists exactly the locked packages
+// with the same versions and references. Packages without a shasum have no archive to check, and packages
+// whose archive is not in the cache, e.g. because they were installed from source, are reported with a warning.
+//
+// With mirrors or dependency mappings, Composer downloads from the URLs in the rewritten copy of composer.lock.
+func verifyComposerIntegrity(logger scribe.Emitter, context packit.BuildContext, settings composerSettings, cacheDir string, installed ComposerInstalled) error {
+	_, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
+	if exists, err := fs.Exists(composerLockPath); err != nil { // untested
+		return err
+	} else if !exists {
+		logger.Process("WARNING: skipping the integrity check, since there is no %s", DefaultComposerLockPath)
+		logger.Break()
+		return nil
+	}
+
+	lock, err := ParseComposerLock(composerLockPath)
+	if err != nil {
+		return err
+	}
+
+	downloadURLs := map[string]string{}
+	for _, p := range lock.AllPackages() {
+		downloadURLs[p.Name] = p.Dist.URL
+	}
+
+	if settings.rewritesDistURLs() {
+		rewrittenLock, err := ParseComposerLock(filepath.Join(context.Layers.Path, ComposerMirrorLayerName, filepath.Base(composerLockPath)))
+		if err != nil {
+			return err
+		}
+		for _, p := range rewrittenLock.AllPackages() {
+			downloadURLs[p.Name] = p.Dist.URL
+		}
+	}
+
+	logger.Process("Verifying the installed packages against %s", DefaultComposerLockPath)
+
+	var problems, unverified []string
+	checked, withShasum := 0, 0
+	for _, p := range lock.AllPackages() {
+		if p.Dist.Shasum == "" {
+			logger.Debug.Subprocess("No dist shasum for %s %s", p.Name, p.Version)
+			continue
+		}
+		withShasum++
+
+		archivePath := composerCacheArchivePath(cacheDir, p, downloadURLs[p.Name])
+		if exists, err := fs.Exists(archivePath); err != nil { // untested
+			return err
+		} else if !exists {
+			unverified = append(unverified, fmt.Sprintf("%s %s", p.Name, p.Version))
+			continue
+		}
+
+		shasum, err := sha1File(archivePath)
+		if err != nil { // untested
+			return err
+		}
+
+		if !strings.EqualFold(shasum, p.Dist.Shasum) {
+			problems = append(problems, fmt.Sprintf("%s %s: archive has shasum %s, but %s expects %s", p.Name, p.Version, shasum, DefaultComposerLockPath, p.Dist.Shasum))
+		}
+		checked++
+	}
+
+	locked := map[string]ComposerLockPackage{}
+	for _, p := range lock.AllPackages() {
+		locked[strings.ToLower(p.Name)] = p
+	}
+
+	installedNames := map[string]bool{}
+	for _, p := range installed.Packages {
+		installedNames[strings.ToLower(p.Name)] = true
+
+		l, ok := locked[strings.ToLower(p.Name)]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s %s is installed, but not in %s", p.Name, p.Version, DefaultComposerLockPath))
+		} else if p.Version != l.Version || p.Reference() != l.Reference() {
+			problems = append(problems, fmt.Sprintf("%s is installed at %s (%s), but locked at %s (%s)", p.Name, p.Version, p.Reference(), l.Version, l.Reference()))
+		}
+	}
+
+	required := lock.Packages
+	if installed.Dev {
+		required = lock.AllPackages()
+	}
+	for _, p := range required {
+		if !installedNames[strings.ToLower(p.Name)] {
+			problems = append(problems, fmt.Sprintf("%s %s is in %s, but not installed", p.Name, p.Version, DefaultComposerLockPath))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d integrity problem(s):\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+
+	// e.g. packages installed from source, or archives removed from the cache
+	if len(unverified) > 0 {
+		logger.Subprocess("WARNING: no archive in the Composer cache to verify for %d package(s) with a dist shasum:", len(unverified))
+		for _, name := range unverified {
+			logger.Action("%s", name)
+		}
+	}
+
+	logger.Subprocess("Verified %d of %d archive(s) against their dist shasum", checked, withShasum)
+	logger.Subprocess("All %d installed package(s) match %s", len(installed.Packages), DefaultComposerLockPath)
+	logger.Break()
+
+	return nil
+}
@@ -0,0 +1,332 @@
+package composer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// ComposerLicensePolicy decides which licenses the locked packages may have
+type ComposerLicensePolicy struct {
+	// Allow lists the permitted SPDX license identifiers. When empty, every license that is not denied is permitted.
+	Allow []string `toml:"allow"`
+
+	// Deny lists the forbidden SPDX license identifiers
+	Deny []string `toml:"deny"`
+
+	// Exceptions maps packages that are exempt from the policy to the reason for the exception
+	Exceptions map[string]string `toml:"exceptions"`
+}
+
+// ComposerLicenseViolation is a locked package whose licenses do not comply with the policy
+type ComposerLicenseViolation struct {
+	Name     string
+	Version  string
+	Licenses []string
+}
+
+// parseComposerLicensePolicy reads the policy file ComposerLicensePolicyFile in the application, and adds
+// the licenses from BP_COMPOSER_LICENSE_ALLOW and BP_COMPOSER_LICENSE_DENY, and the packages from
+// BP_COMPOSER_LICENSE_EXCEPTIONS. It returns false if no policy is configured.
+func parseComposerLicensePolicy(workingDir string) (ComposerLicensePolicy, bool, error) {
+	var policy ComposerLicensePolicy
+	configured := false
+
+	policyPath := filepath.Join(workingDir, ComposerLicensePolicyFile)
+	if exists, err := fs.Exists(policyPath); err != nil { // untested
+		return ComposerLicensePolicy{}, false, err
+	} else if exists {
+		_, err = toml.DecodeFile(policyPath, &policy)
+		if err != nil {
+			return ComposerLicensePolicy{}, false, fmt.Errorf("failed to parse %s: %w", ComposerLicensePolicyFile, err)
+		}
+		configured = true
+	}
+
+	split := func(value string) []string {
+		return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' })
+	}
+
+	if value, found := os.LookupEnv(BpComposerLicenseAllow); found {
+		policy.Allow = append(policy.Allow, split(value)...)
+		configured = true
+	}
+
+	if value, found := os.LookupEnv(BpComposerLicenseDeny); found {
+		policy.Deny = append(policy.Deny, split(value)...)
+		configured = true
+	}
+
+	if value, found := os.LookupEnv(BpComposerLicenseExceptions); found {
+		if policy.Exceptions == nil {
+			policy.Exceptions = map[string]string{}
+		}
+		for _, name := range split(value) {
+			policy.Exceptions[name] = fmt.Sprintf("set in %s", BpComposerLicenseExceptions)
+		}
+	}
+
+	return policy, configured, nil
+}
+
+// Evaluate returns the locked packages that violate the policy. Composer lists the licenses of a package
+// that is available under any of several licenses as separate entries, and each entry may be an SPDX
+// license expression, e.g. `(MIT or GPL-2.0+)`. A package complies when any entry is permitted.
+func (p ComposerLicensePolicy) Evaluate(lock ComposerLock) []ComposerLicenseViolation {
+	var violations []ComposerLicenseViolation
+	for _, pkg := range lock.AllPackages() {
+		if p.exempts(pkg.Name) {
+			continue
+		}
+
+		complies := false
+		for _, license := range pkg.License {
+			if p.permitsExpression(license) {
+				complies = true
+				break
+			}
+		}
+
+		// packages without a license only comply with a policy that does not restrict to certain licenses
+		if len(pkg.License) == 0 && len(p.Allow) == 0 {
+			complies = true
+		}
+
+		if !complies {
+			violations = append(violations, ComposerLicenseViolation{
+				Name:     pkg.Name,
+				Version:  pkg.Version,
+				Licenses: pkg.License,
+			})
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Name < violations[j].Name })
+
+	return violations
+}
+
+func (p ComposerLicensePolicy) exempts(name string) bool {
+	for exception := range p.Exceptions {
+		if matchesPattern(exception, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// permitsExpression evaluates an SPDX license expression. Expressions that cannot be parsed are
+// treated as a single license identifier.
+func (p ComposerLicensePolicy) permitsExpression(expression string) bool {
+	parser := spdxExpressionParser{tokens: tokenizeSPDXExpression(expression), permits: p.permits}
+
+	permitted, err := parser.parseOr()
+	if err != nil || parser.position != len(parser.tokens) {
+		return p.permits(strings.TrimSpace(expression))
+	}
+
+	return permitted
+}
+
+// permits returns whether a license is permitted, given the names it is known by: it must not match
+// any of the denied licenses, and must match one of the allowed licenses, if any
+func (p ComposerLicensePolicy) permits(names ...string) bool {
+	for _, denied := range p.Deny {
+		for _, name := range names {
+			if matchesLicense(denied, name) {
+				return false
+			}
+		}
+	}
+
+	if len(p.Allow) == 0 {
+		return true
+	}
+
+	for _, allowed := range p.Allow {
+		for _, name := range names {
+			if matchesLicense(allowed, name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchesLicense returns whether the license matches the pattern, ignoring case and the `+`, `-only` and
+// `-or-later` variants of a license, so that e.g. `GPL-2.0` also matches `GPL-2.0-or-later`
+func matchesLicense(pattern, license string) bool {
+	return matchesPattern(pattern, license) || matchesPattern(baseLicense(pattern), baseLicense(license))
+}
+
+func baseLicense(license string) string {
+	license = strings.TrimSuffix(license, "+")
+	license = strings.TrimSuffix(license, "-only")
+	return strings.TrimSuffix(license, "-or-later")
+}
+
+// matchesPattern matches case-insensitively, with `*` matching any part of a name
+func matchesPattern(pattern, value string) bool {
+	matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+	return err == nil && matched
+}
+
+// tokenizeSPDXExpression splits an SPDX license expression into parentheses and words
+func tokenizeSPDXExpression(expression string) []string {
+	expression = strings.ReplaceAll(expression, "(", " ( ")
+	expression = strings.ReplaceAll(expression, ")", " ) ")
+	return strings.Fields(expression)
+}
+
+// spdxExpressionParser evaluates an SPDX license expression as it parses it
+//
+// https://spdx.github.io/spdx-spec/v2.3/SPDX-license-expressions/
+type spdxExpressionParser struct {
+	tokens   []string
+	position int
+	permits  func(names ...string) bool
+}
+
+func (s *spdxExpressionParser) peek() string {
+	if s.position < len(s.tokens) {
+		return s.tokens[s.position]
+	}
+	return ""
+}
+
+func (s *spdxExpressionParser) next() string {
+	token := s.peek()
+	s.position++
+	return token
+}
+
+// parseOr parses `and-expression [OR and-expression]...`
+func (s *spdxExpressionParser) parseOr() (bool, error) {
+	permitted, err := s.parseAnd()
+	if err != nil {
+		return false, err
+	}
+
+	for strings.EqualFold(s.peek(), "or") {
+		s.next()
+		operand, err := s.parseAnd()
+		if err != nil {
+			return false, err
+		}
+		permitted = permitted || operand
+	}
+
+	return permitted, nil
+}
+
+// parseAnd parses `license [AND license]...`
+func (s *spdxExpressionParser) parseAnd() (bool, error) {
+	permitted, err := s.parseLicense()
+	if err != nil {
+		return false, err
+	}
+
+	for strings.EqualFold(s.peek(), "and") {
+		s.next()
+		operand, err := s.parseLicense()
+		if err != nil {
+			return false, err
+		}
+		permitted = permitted && operand
+	}
+
+	return permitted, nil
+}
+
+// parseLicense parses `( expression )` or `identifier [WITH exception]`. A license with an exception
+// is known both by the license and by the full `license WITH exception`.
+func (s *spdxExpressionParser) parseLicense() (bool, error) {
+	token := s.next()
+	switch {
+	case token == "(":
+		permitted, err := s.parseOr()
+		if err != nil {
+			return false, err
+		}
+		if s.next() != ")" {
+			return false, fmt.Errorf("missing ')'")
+		}
+		return permitted, nil
+
+	case token == "", token == ")", strings.EqualFold(token, "and"), strings.EqualFold(token, "or"), strings.EqualFold(token, "with"):
+		return false, fmt.Errorf("unexpected '%s'", token)
+	}
+
+	if strings.EqualFold(s.peek(), "with") {
+		s.next()
+		exception := s.next()
+		if exception == "" || exception == "(" || exception == ")" {
+			return false, fmt.Errorf("missing exception")
+		}
+		return s.permits(token, fmt.Sprintf("%s WITH %s", token, exception)), nil
+	}
+
+	return s.permits(token), nil
+}
+
+// checkComposerLicenses evaluates the packages in the composer.lock of the application against the license
+// policy, if one is configured, and fails with a table of the packages that violate it
+func checkComposerLicenses(logger scribe.Emitter, context packit.BuildContext) error {
+	policy, configured, err := parseComposerLicensePolicy(context.WorkingDir)
+	if err != nil {
+		return err
+	}
+
+	if !configured {
+		return nil
+	}
+
+	_, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
+	lock, err := ParseComposerLock(composerLockPath)
+	if err != nil {
+		return err
+	}
+
+	logger.Process("Checking the licenses of %d locked package(s)", len(lock.AllPackages()))
+
+	var exceptions []string
+	for name := range policy.Exceptions {
+		exceptions = append(exceptions, name)
+	}
+	sort.Strings(exceptions)
+	for _, name := range exceptions {
+		logger.Subprocess("Exception for %s: %s", name, policy.Exceptions[name])
+	}
+
+	violations := policy.Evaluate(lock)
+	if len(violations) == 0 {
+		logger.Subprocess("All packages comply with the license policy")
+		logger.Break()
+		return nil
+	}
+
+	table := bytes.NewBuffer(nil)
+	writer := tabwriter.NewWriter(table, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "  PACKAGE\tVERSION\tLICENSE")
+	for _, violation := range violations {
+		licenses := strings.Join(violation.Licenses, ", ")
+		if licenses == "" {
+			licenses = "(none)"
+		}
+		_, _ = fmt.Fprintf(writer, "  %s\t%s\t%s\n", violation.Name, violation.Version, licenses)
+	}
+	_ = writer.Flush()
+
+	return fmt.Errorf("%d package(s) violate the license policy:\n%s", len(violations), strings.TrimRight(table.String(), "\n"))
+}
@@ -0,0 +1,89 @@
+package composer_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testComposerLicensePolicy(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		lock composer.ComposerLock
+	)
+
+	it.Before(func() {
+		lock = composer.ComposerLock{
+			Packages: []composer.ComposerLockPackage{
+				{Name: "vendor/mit", Version: "1.0.0", License: []string{"MIT"}},
+				{Name: "vendor/gpl", Version: "2.0.0", License: []string{"GPL-2.0-or-later"}},
+				{Name: "vendor/dual", Version: "3.0.0", License: []string{"(MIT or GPL-2.0+)"}},
+				{Name: "vendor/both", Version: "4.0.0", License: []string{"MIT AND GPL-3.0-only"}},
+				{Name: "vendor/classpath", Version: "5.0.0", License: []string{"GPL-2.0-only WITH Classpath-exception-2.0"}},
+			},
+			PackagesDev: []composer.ComposerLockPackage{
+				{Name: "vendor/unlicensed", Version: "6.0.0"},
+				{Name: "vendor/listed", Version: "7.0.0", License: []string{"LGPL-3.0", "BSD-3-Clause"}},
+			},
+		}
+	})
+
+	context("with a deny list", func() {
+		it("reports the packages that can only be used under denied licenses", func() {
+			policy := composer.ComposerLicensePolicy{Deny: []string{"GPL-*"}}
+			Expect(policy.Evaluate(lock)).To(Equal([]composer.ComposerLicenseViolation{
+				{Name: "vendor/both", Version: "4.0.0", Licenses: []string{"MIT AND GPL-3.0-only"}},
+				{Name: "vendor/classpath", Version: "5.0.0", Licenses: []string{"GPL-2.0-only WITH Classpath-exception-2.0"}},
+				{Name: "vendor/gpl", Version: "2.0.0", Licenses: []string{"GPL-2.0-or-later"}},
+			}))
+		})
+
+		it("matches the variants of a license", func() {
+			policy := composer.ComposerLicensePolicy{Deny: []string{"gpl-2.0"}}
+			Expect(policy.Evaluate(lock)).To(Equal([]composer.ComposerLicenseViolation{
+				{Name: "vendor/classpath", Version: "5.0.0", Licenses: []string{"GPL-2.0-only WITH Classpath-exception-2.0"}},
+				{Name: "vendor/gpl", Version: "2.0.0", Licenses: []string{"GPL-2.0-or-later"}},
+			}))
+		})
+	})
+
+	context("with an allow list", func() {
+		it("reports the packages without an allowed license", func() {
+			policy := composer.ComposerLicensePolicy{
+				Allow: []string{"MIT", "BSD-*", "GPL-2.0-only WITH Classpath-exception-2.0"},
+			}
+			Expect(policy.Evaluate(lock)).To(Equal([]composer.ComposerLicenseViolation{
+				{Name: "vendor/both", Version: "4.0.0", Licenses: []string{"MIT AND GPL-3.0-only"}},
+				{Name: "vendor/gpl", Version: "2.0.0", Licenses: []string{"GPL-2.0-or-later"}},
+				{Name: "vendor/unlicensed", Version: "6.0.0"},
+			}))
+		})
+	})
+
+	context("with exceptions", func() {
+		it("skips the exempt packages", func() {
+			policy := composer.ComposerLicensePolicy{
+				Deny:       []string{"GPL-*"},
+				Exceptions: map[string]string{"vendor/gpl": "approved", "Vendor/Cl*": "approved"},
+			}
+			Expect(policy.Evaluate(lock)).To(Equal([]composer.ComposerLicenseViolation{
+				{Name: "vendor/both", Version: "4.0.0", Licenses: []string{"MIT AND GPL-3.0-only"}},
+			}))
+		})
+	})
+
+	context("with an invalid license expression", func() {
+		it("treats it as a single license", func() {
+			lock = composer.ComposerLock{Packages: []composer.ComposerLockPackage{
+				{Name: "vendor/broken", Version: "1.0.0", License: []string{"(MIT or"}},
+			}}
+
+			Expect(composer.ComposerLicensePolicy{Allow: []string{"MIT"}}.Evaluate(lock)).To(HaveLen(1))
+			Expect(composer.ComposerLicensePolicy{Allow: []string{"(MIT or"}}.Evaluate(lock)).To(BeEmpty())
+		})
+	})
+}
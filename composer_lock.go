@@ -0,0 +1,120 @@
+package composer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+// ComposerLock is the subset of `composer.lock` used by this buildpack
+// https://getcomposer.org/doc/01-basic-usage.md#commit-your-composer-lock-file-to-version-control
+type ComposerLock struct {
+	Packages    []ComposerLockPackage `json:"packages"`
+	PackagesDev []ComposerLockPackage `json:"packages-dev"`
+}
+
+// ComposerLockPackage is a single locked package
+type ComposerLockPackage struct {
+	Name    string                    `json:"name"`
+	Version string                    `json:"version"`
+	Type    string                    `json:"type"`
+	Source  ComposerLockPackageSource `json:"source"`
+	Dist    ComposerLockPackageDist   `json:"dist"`
+	License []string                  `json:"license"`
+
+	// Abandoned is set when the package is no longer maintained, possibly with a suggested replacement
+	Abandoned ComposerAbandoned `json:"abandoned"`
+
+	// InstallPath is only set in vendor/composer/installed.json, relative to vendor/composer. It is empty for
+	// packages without files, such as metapackages.
+	InstallPath string `json:"install-path"`
+}
+
+// ComposerLockPackageSource describes where the package source can be checked out from
+type ComposerLockPackageSource struct {
+	Type      string `json:"type"`
+	URL       string `json:"url"`
+	Reference string `json:"reference"`
+}
+
+// ComposerLockPackageDist describes where the package archive can be downloaded from
+type ComposerLockPackageDist struct {
+	Type      string `json:"type"`
+	URL       string `json:"url"`
+	Reference string `json:"reference"`
+	Shasum    string `json:"shasum"`
+}
+
+// ParseComposerLock reads the `composer.lock` at the given path.
+// Since a `composer.lock` is not required, an empty ComposerLock is returned if it does not exist.
+func ParseComposerLock(composerLockPath string) (ComposerLock, error) {
+	if exists, err := fs.Exists(composerLockPath); err != nil {
+		return ComposerLock{}, err
+	} else if !exists {
+		return ComposerLock{}, nil
+	}
+
+	content, err := os.ReadFile(composerLockPath)
+	if err != nil {
+		return ComposerLock{}, err
+	}
+
+	var lock ComposerLock
+	err = json.Unmarshal(content, &lock)
+	if err != nil {
+		return ComposerLock{}, err
+	}
+
+	return lock, nil
+}
+
+// ComposerInstalled is the subset of `vendor/composer/installed.json`, which lists the packages that Composer installed
+type ComposerInstalled struct {
+	Packages []ComposerLockPackage `json:"packages"`
+
+	// Dev is set when the `require-dev` packages were installed
+	Dev bool `json:"dev"`
+}
+
+// readComposerInstalled reads vendor/composer/installed.json, which is an object with a `packages` list
+// since Composer 2, or a list before. It returns nothing if the file does not exist.
+func readComposerInstalled(vendorDir string) (ComposerInstalled, error) {
+	installedPath := filepath.Join(vendorDir, "composer", "installed.json")
+	if exists, err := fs.Exists(installedPath); err != nil { // untested
+		return ComposerInstalled{}, err
+	} else if !exists {
+		return ComposerInstalled{}, nil
+	}
+
+	content, err := os.ReadFile(installedPath)
+	if err != nil { // untested
+		return ComposerInstalled{}, err
+	}
+
+	var installed ComposerInstalled
+	if err := json.Unmarshal(content, &installed); err != nil {
+		var packages []ComposerLockPackage
+		if err := json.Unmarshal(content, &packages); err != nil {
+			return ComposerInstalled{}, fmt.Errorf("failed to parse %s: %w", installedPath, err)
+		}
+		return ComposerInstalled{Packages: packages}, nil
+	}
+
+	return installed, nil
+}
+
+// AllPackages returns both the regular and the dev packages
+func (l ComposerLock) AllPackages() []ComposerLockPackage {
+	return append(append([]ComposerLockPackage{}, l.Packages...), l.PackagesDev...)
+}
+
+// Reference returns the commit or tag the package was locked at, preferring the source reference
+func (p ComposerLockPackage) Reference() string {
+	if p.Source.Reference != "" {
+		return p.Source.Reference
+	}
+	return p.Dist.Reference
+}
@@ -0,0 +1,251 @@
+package composer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// packagistHosts are the hosts of the packagist.org Composer repository
+var packagistHosts = []string{"packagist.org", "repo.packagist.org"}
+
+// ComposerMirror maps download URLs starting with Prefix to the same path under URL
+type ComposerMirror struct {
+	Prefix string `json:"prefix"`
+	URL    string `json:"url"`
+}
+
+// parseComposerMirrors reads BP_COMPOSER_MIRROR, a comma-separated list of `prefix=mirror` URL pairs.
+// The mirrors are returned longest prefix first, so that the most specific mirror is used.
+func parseComposerMirrors() ([]ComposerMirror, error) {
+	value := os.Getenv(BpComposerMirror)
+
+	var mirrors []ComposerMirror
+	for _, pair := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		prefix, mirror, found := strings.Cut(pair, "=")
+		if !found || !isHTTPURL(prefix) || !isHTTPURL(mirror) {
+			return nil, fmt.Errorf("invalid value for %s: '%s', must be a comma-separated list of 'prefix=mirror' URL pairs", BpComposerMirror, pair)
+		}
+
+		mirrors = append(mirrors, ComposerMirror{
+			Prefix: strings.TrimSuffix(prefix, "/"),
+			URL:    strings.TrimSuffix(mirror, "/"),
+		})
+	}
+
+	sort.SliceStable(mirrors, func(i, j int) bool { return len(mirrors[i].Prefix) > len(mirrors[j].Prefix) })
+
+	return mirrors, nil
+}
+
+func isHTTPURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// rewriteMirroredURL returns the URL of the download on the first matching mirror
+func rewriteMirroredURL(mirrors []ComposerMirror, downloadURL string) (string, bool) {
+	for _, mirror := range mirrors {
+		if downloadURL == mirror.Prefix || strings.HasPrefix(downloadURL, mirror.Prefix+"/") {
+			return mirror.URL + strings.TrimPrefix(downloadURL, mirror.Prefix), true
+		}
+	}
+	return downloadURL, false
+}
+
+// composerMirrorHomeConfig returns the config.json for COMPOSER_HOME that replaces packagist.org with
+// its mirror, or nil if packagist.org is not mirrored. This is used to resolve packages that are not
+// locked, e.g. by `composer global require`.
+func composerMirrorHomeConfig(mirrors []ComposerMirror) map[string]interface{} {
+	for _, mirror := range mirrors {
+		u, _ := url.Parse(mirror.Prefix)
+		for _, host := range packagistHosts {
+			if u.Host == host && strings.Trim(u.Path, "/") == "" {
+				return map[string]interface{}{
+					"repositories": []interface{}{
+						map[string]interface{}{"type": "composer", "url": mirror.URL},
+						map[string]interface{}{"packagist.org": false},
+					},
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writeComposerHomeConfig writes the given config as config.json into COMPOSER_HOME
+func writeComposerHomeConfig(composerHome string, config map[string]interface{}) error {
+	if config == nil {
+		return nil
+	}
+
+	err := os.MkdirAll(composerHome, os.ModePerm)
+	if err != nil { // untested
+		return err
+	}
+
+	content, err := json.MarshalIndent(config, "", "    ")
+	if err != nil { // untested
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(composerHome, "config.json"), content, 0644)
+}
+
+// writeMirroredComposerFiles copies the composer.json and composer.lock into the composer-mirror layer,
+// with the dist URLs of the locked packages rewritten to their mirror or local archive, and returns the
+// path of the copied composer.json for use as COMPOSER. The files in the application are not modified.
+// The layer is not returned from Build.
+func writeMirroredComposerFiles(logger scribe.Emitter, context packit.BuildContext, composerJsonPath, composerLockPath string, settings composerSettings) (string, error) {
+	if exists, err := fs.Exists(composerLockPath); err != nil { // untested
+		return "", err
+	} else if !exists && settings.offline {
+		return "", fmt.Errorf("a %s is required to install packages from dependency mappings", filepath.Base(composerLockPath))
+	} else if !exists {
+		return composerJsonPath, nil
+	}
+
+	mirrorLayer, err := context.Layers.Get(ComposerMirrorLayerName)
+	if err != nil { // untested
+		return "", err
+	}
+
+	mirrorLayer, err = mirrorLayer.Reset()
+	if err != nil { // untested
+		return "", err
+	}
+
+	// Composer finds the lock file next to COMPOSER, with the same name
+	mirroredComposerJsonPath := filepath.Join(mirrorLayer.Path, filepath.Base(composerJsonPath))
+	err = fs.Copy(composerJsonPath, mirroredComposerJsonPath)
+	if err != nil { // untested
+		return "", err
+	}
+
+	mirroredComposerLockPath := filepath.Join(mirrorLayer.Path, filepath.Base(composerLockPath))
+	err = fs.Copy(composerLockPath, mirroredComposerLockPath)
+	if err != nil { // untested
+		return "", err
+	}
+
+	err = rewriteComposerLockURLs(logger, mirroredComposerLockPath, settings)
+	if err != nil {
+		return "", err
+	}
+
+	return mirroredComposerJsonPath, nil
+}
+
+// rewriteComposerLockURLs rewrites the dist URLs of the packages in the composer.lock at the given path.
+// With dependency mappings, every package is pointed at its local archive, and the source is removed so
+// that Composer cannot fall back to cloning it; packages without a mapping are an error. Otherwise, the
+// dist URLs are rewritten to their mirror. Since the content-hash only covers composer.json, Composer
+// still considers the lock file up to date.
+func rewriteComposerLockURLs(logger scribe.Emitter, composerLockPath string, settings composerSettings) error {
+	content, err := os.ReadFile(composerLockPath)
+	if err != nil { // untested
+		return err
+	}
+
+	var lock map[string]interface{}
+	err = json.Unmarshal(content, &lock)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", filepath.Base(composerLockPath), err)
+	}
+
+	rewritten := 0
+	var unmapped []string
+	for _, key := range []string{"packages", "packages-dev"} {
+		packages, _ := lock[key].([]interface{})
+		for _, item := range packages {
+			p, _ := item.(map[string]interface{})
+			if p == nil {
+				continue
+			}
+
+			name, _ := p["name"].(string)
+			version, _ := p["version"].(string)
+			source, _ := p["source"].(map[string]interface{})
+			dist, _ := p["dist"].(map[string]interface{})
+			distURL, _ := dist["url"].(string)
+
+			if settings.dependencyMappings == nil {
+				if mirrored, ok := rewriteMirroredURL(settings.mirrors, distURL); ok {
+					dist["url"] = mirrored
+					rewritten++
+				}
+				continue
+			}
+
+			// packages from path repositories are already local, and metapackages have nothing to download
+			if dist["type"] == "path" || (dist == nil && source == nil) {
+				continue
+			}
+
+			reference, _ := source["reference"].(string)
+			if reference == "" {
+				reference, _ = dist["reference"].(string)
+			}
+
+			archive, ok := settings.dependencyMappings.Archive(name, reference, distURL)
+			if !ok {
+				location := distURL
+				if location == "" {
+					location, _ = source["url"].(string)
+				}
+				unmapped = append(unmapped, fmt.Sprintf("%s %s (%s)", name, version, location))
+				continue
+			}
+
+			if dist == nil {
+				dist = map[string]interface{}{"type": archiveType(archive), "reference": reference}
+				p["dist"] = dist
+			}
+			dist["url"] = archive
+			delete(p, "source")
+			rewritten++
+		}
+	}
+
+	if len(unmapped) > 0 {
+		return fmt.Errorf("no dependency mapping found for the following locked packages, refusing to download them:\n  %s", strings.Join(unmapped, "\n  "))
+	}
+
+	if settings.dependencyMappings != nil {
+		logger.Process("Installing %d locked package(s) from local archives", rewritten)
+	} else {
+		logger.Process("Downloading %d locked package(s) from mirrors", rewritten)
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+	err = encoder.Encode(lock)
+	if err != nil { // untested
+		return err
+	}
+
+	return os.WriteFile(composerLockPath, buffer.Bytes(), 0644)
+}
+
+// archiveType returns the Composer dist type for a local archive
+func archiveType(archive string) string {
+	switch {
+	case strings.HasSuffix(archive, ".zip"):
+		return "zip"
+	case strings.HasSuffix(archive, ".tar"), strings.HasSuffix(archive, ".tar.gz"), strings.HasSuffix(archive, ".tgz"):
+		return "tar"
+	default:
+		return "file"
+	}
+}
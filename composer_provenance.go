@@ -0,0 +1,381 @@
+package composer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+const (
+	// ComposerProvenanceFile is written into the composer-packages layer
+	ComposerProvenanceFile = "provenance.intoto.json"
+
+	// ComposerProvenanceLabel is the launch label holding the provenance when BP_COMPOSER_PROVENANCE_LABEL is set
+	ComposerProvenanceLabel = "io.paketo.composer.provenance"
+
+	// ComposerProvenanceBuildType documents the format of the build definition
+	ComposerProvenanceBuildType = "https://github.com/paketo-buildpacks/composer-install/blob/main/README.md#provenance"
+)
+
+var (
+	composerVersionOutputPattern = regexp.MustCompile(`(?m)^Composer (?:version )?(\S+)`)
+	phpVersionOutputPattern      = regexp.MustCompile(`(?m)^PHP version (\S+)`)
+)
+
+// ComposerProvenance is an in-toto statement with a SLSA provenance predicate for the vendor directory
+//
+// https://github.com/in-toto/attestation/blob/main/spec/v1/statement.md
+// https://slsa.dev/spec/v1.0/provenance
+type ComposerProvenance struct {
+	Type          string                      `json:"_type"`
+	Subject       []ProvenanceResource        `json:"subject"`
+	PredicateType string                      `json:"predicateType"`
+	Predicate     ComposerProvenancePredicate `json:"predicate"`
+}
+
+// ProvenanceResource is an in-toto resource descriptor
+type ProvenanceResource struct {
+	Name        string                 `json:"name,omitempty"`
+	URI         string                 `json:"uri,omitempty"`
+	Digest      map[string]string      `json:"digest,omitempty"`
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
+}
+
+// ComposerProvenancePredicate is the SLSA provenance of the vendor directory
+type ComposerProvenancePredicate struct {
+	BuildDefinition ComposerProvenanceBuildDefinition `json:"buildDefinition"`
+	RunDetails      ComposerProvenanceRunDetails      `json:"runDetails"`
+}
+
+// ComposerProvenanceBuildDefinition describes the inputs of the install
+type ComposerProvenanceBuildDefinition struct {
+	BuildType            string                               `json:"buildType"`
+	ExternalParameters   ComposerProvenanceExternalParameters `json:"externalParameters"`
+	InternalParameters   ComposerProvenanceInternalParameters `json:"internalParameters"`
+	ResolvedDependencies []ProvenanceResource                 `json:"resolvedDependencies"`
+}
+
+// ComposerProvenanceExternalParameters are the parameters of the install that the application controls
+type ComposerProvenanceExternalParameters struct {
+	// InstallOptions are the options of the `composer install` that produced the vendor directory
+	InstallOptions []string `json:"installOptions"`
+}
+
+// ComposerProvenanceInternalParameters describe the environment of the install
+type ComposerProvenanceInternalParameters struct {
+	ComposerVersion string           `json:"composerVersion"`
+	PhpVersion      string           `json:"phpVersion"`
+	Offline         bool             `json:"offline"`
+	Mirrors         []ComposerMirror `json:"mirrors"`
+}
+
+// ComposerProvenanceRunDetails describes the build that ran the install
+type ComposerProvenanceRunDetails struct {
+	Builder  ComposerProvenanceBuilder  `json:"builder"`
+	Metadata ComposerProvenanceMetadata `json:"metadata"`
+}
+
+// ComposerProvenanceBuilder identifies this buildpack
+type ComposerProvenanceBuilder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version"`
+}
+
+// ComposerProvenanceMetadata holds the timestamps of the build
+type ComposerProvenanceMetadata struct {
+	StartedOn  time.Time `json:"startedOn"`
+	FinishedOn time.Time `json:"finishedOn"`
+}
+
+// provenanceLabelEnabled reads BP_COMPOSER_PROVENANCE_LABEL
+func provenanceLabelEnabled() (bool, error) {
+	value, found := os.LookupEnv(BpComposerProvenanceLabel)
+	if !found || value == "" {
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for %s: '%s', must be a boolean", BpComposerProvenanceLabel, value)
+	}
+
+	return enabled, nil
+}
+
+// composerVersions runs `composer --version`, which prints the versions of Composer and of PHP. Versions
+// that are not printed, such as the PHP version by Composer before 2.3, are empty.
+func composerVersions(logger scribe.Emitter, context packit.BuildContext, composerVersionExec Executable, composerPhpIniPath, path string, settings composerSettings) (string, string, error) {
+	stdout := bytes.NewBuffer(nil)
+	execution := pexec.Execution{
+		Args: []string{"--version", "--no-ansi"},
+		Dir:  context.WorkingDir,
+		Env: settings.environ(logger,
+			"COMPOSER_NO_INTERACTION=1", // https://getcomposer.org/doc/03-cli.md#composer-no-interaction
+			fmt.Sprintf("PHPRC=%s", composerPhpIniPath),
+			fmt.Sprintf("PATH=%s", path),
+		),
+		Stdout: stdout,
+		Stderr: logger.ActionWriter,
+	}
+
+	err := composerVersionExec.Execute(execution)
+	flushActionWriter(logger)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to run composer --version: %w", err)
+	}
+
+	var composerVersion, phpVersion string
+	if matches := composerVersionOutputPattern.FindStringSubmatch(stdout.String()); matches != nil {
+		composerVersion = matches[1]
+	}
+	if matches := phpVersionOutputPattern.FindStringSubmatch(stdout.String()); matches != nil {
+		phpVersion = matches[1]
+	}
+
+	return composerVersion, phpVersion, nil
+}
+
+// composerRepositories lists the repositories in composer.json and in the config.json written into
+// COMPOSER_HOME, followed by packagist.org unless one of them disables it. Credentials are removed from the URLs.
+func composerRepositories(composerJsonPath string, settings composerSettings) ([]ProvenanceResource, error) {
+	composerJson := map[string]interface{}{}
+	if exists, err := fs.Exists(composerJsonPath); err != nil { // untested
+		return nil, err
+	} else if exists {
+		composerJson, err = readComposerJson(composerJsonPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var entries []interface{}
+	for _, config := range []map[string]interface{}{composerJson, settings.homeConfig} {
+		switch repositories := config["repositories"].(type) {
+		case []interface{}:
+			entries = append(entries, repositories...)
+		case map[string]interface{}:
+			for _, name := range sortedKeys(repositories) {
+				if repository, ok := repositories[name].(map[string]interface{}); ok {
+					entries = append(entries, repository)
+				} else {
+					entries = append(entries, map[string]interface{}{name: repositories[name]})
+				}
+			}
+		}
+	}
+
+	packagist := true
+	var resources []ProvenanceResource
+	for _, entry := range entries {
+		repository, _ := entry.(map[string]interface{})
+		if enabled, ok := repository["packagist.org"].(bool); ok && !enabled {
+			packagist = false
+			continue
+		}
+
+		repositoryType, _ := repository["type"].(string)
+		repositoryURL, _ := repository["url"].(string)
+		if repositoryType == "" {
+			continue
+		}
+
+		resources = append(resources, ProvenanceResource{URI: withoutCredentials(repositoryURL), Annotations: map[string]interface{}{"repositoryType": repositoryType}})
+	}
+
+	if packagist {
+		resources = append(resources, ProvenanceResource{URI: "https://repo.packagist.org", Annotations: map[string]interface{}{"repositoryType": "composer"}})
+	}
+
+	return resources, nil
+}
+
+// withoutCredentials removes the userinfo from a URL, so that credentials do not end up in the image
+func withoutCredentials(value string) string {
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		u.User = nil
+		return u.String()
+	}
+	return value
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fileDigest returns the hex-encoded SHA-256 checksum of a file
+func fileDigest(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil { // untested
+		return "", err
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// vendorDirDigest returns the in-toto "dirHash" digest of the vendor directory, which is Go's dirhash.Hash1 over
+// its files, named with a "vendor/" prefix. Symlinks are followed, and left out when they do not point to a file.
+//
+// https://github.com/in-toto/attestation/blob/main/spec/v1/digest_set.md
+func vendorDirDigest(vendorDir string) (string, error) {
+	files, err := dirhash.DirFiles(vendorDir, "vendor")
+	if err != nil { // untested
+		return "", err
+	}
+
+	var regularFiles []string
+	for _, file := range files {
+		info, err := os.Stat(filepath.Join(vendorDir, strings.TrimPrefix(file, "vendor/")))
+		if err == nil && info.Mode().IsRegular() {
+			regularFiles = append(regularFiles, file)
+		}
+	}
+
+	return dirhash.Hash1(regularFiles, func(file string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(vendorDir, strings.TrimPrefix(file, "vendor/")))
+	})
+}
+
+// writeComposerProvenance writes the provenance of the vendor directory into the composer-packages layer, once the
+// layer has been built. Files are identified by their SHA-256 checksum and the vendor directory by its dirHash. It
+// returns the statement in its compact form, to be used as a launch label.
+func writeComposerProvenance(
+	context packit.BuildContext,
+	composerPackagesLayer packit.Layer,
+	globalPackages []ComposerLockPackage,
+	composerVersion, phpVersion string,
+	settings composerSettings,
+	startedOn, finishedOn time.Time) (string, error) {
+
+	composerJsonPath, composerLockPath, _, _ := FindComposerFiles(context.WorkingDir)
+
+	var dependencies []ProvenanceResource
+	for _, file := range []string{composerJsonPath, composerLockPath} {
+		if exists, err := fs.Exists(file); err != nil { // untested
+			return "", err
+		} else if !exists {
+			continue
+		}
+
+		checksum, err := fileDigest(file)
+		if err != nil { // untested
+			return "", err
+		}
+
+		dependencies = append(dependencies, ProvenanceResource{Name: filepath.Base(file), Digest: map[string]string{"sha256": checksum}})
+	}
+
+	for _, p := range globalPackages {
+		dependencies = append(dependencies, ProvenanceResource{
+			URI:         fmt.Sprintf("pkg:composer/%s@%s", p.Name, p.Version),
+			Annotations: map[string]interface{}{"global": true},
+		})
+	}
+
+	repositories, err := composerRepositories(composerJsonPath, settings)
+	if err != nil {
+		return "", err
+	}
+	dependencies = append(dependencies, repositories...)
+
+	mirrors := []ComposerMirror{}
+	for _, mirror := range settings.mirrors {
+		mirrors = append(mirrors, ComposerMirror{Prefix: withoutCredentials(mirror.Prefix), URL: withoutCredentials(mirror.URL)})
+	}
+
+	vendorDigest, err := vendorDirDigest(filepath.Join(composerPackagesLayer.Path, "vendor"))
+	if err != nil { // untested
+		return "", err
+	}
+
+	provenance := ComposerProvenance{
+		Type:          "https://in-toto.io/Statement/v1",
+		Subject:       []ProvenanceResource{{Name: "vendor", Digest: map[string]string{"dirHash": vendorDigest}}},
+		PredicateType: "https://slsa.dev/provenance/v1",
+		Predicate: ComposerProvenancePredicate{
+			BuildDefinition: ComposerProvenanceBuildDefinition{
+				BuildType: ComposerProvenanceBuildType,
+				ExternalParameters: ComposerProvenanceExternalParameters{
+					InstallOptions: composerInstallOptionsFromMetadata(composerPackagesLayer.Metadata["install-options"]),
+				},
+				InternalParameters: ComposerProvenanceInternalParameters{
+					ComposerVersion: composerVersion,
+					PhpVersion:      phpVersion,
+					Offline:         settings.offline,
+					Mirrors:         mirrors,
+				},
+				ResolvedDependencies: dependencies,
+			},
+			RunDetails: ComposerProvenanceRunDetails{
+				Builder: ComposerProvenanceBuilder{
+					ID:      context.BuildpackInfo.ID,
+					Version: map[string]string{context.BuildpackInfo.ID: context.BuildpackInfo.Version},
+				},
+				Metadata: ComposerProvenanceMetadata{
+					StartedOn:  startedOn.UTC(),
+					FinishedOn: finishedOn.UTC(),
+				},
+			},
+		},
+	}
+
+	content, err := json.MarshalIndent(provenance, "", "  ")
+	if err != nil { // untested
+		return "", err
+	}
+
+	err = os.WriteFile(filepath.Join(composerPackagesLayer.Path, ComposerProvenanceFile), content, 0644)
+	if err != nil { // untested
+		return "", err
+	}
+
+	return compactProvenance(content)
+}
+
+// readComposerProvenance reads the provenance of a reused composer-packages layer, which is empty when the layer was
+// built by a version of this buildpack that did not write one
+func readComposerProvenance(logger scribe.Emitter, composerPackagesLayer packit.Layer) (string, error) {
+	content, err := os.ReadFile(filepath.Join(composerPackagesLayer.Path, ComposerProvenanceFile))
+	if errors.Is(err, os.ErrNotExist) {
+		logger.Process("WARNING: the cached layer %s has no provenance, it is written when the layer is rebuilt", composerPackagesLayer.Path)
+		logger.Break()
+		return "", nil
+	} else if err != nil { // untested
+		return "", err
+	}
+
+	return compactProvenance(content)
+}
+
+func compactProvenance(content []byte) (string, error) {
+	compact := bytes.NewBuffer(nil)
+	err := json.Compact(compact, content)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", ComposerProvenanceFile, err)
+	}
+
+	return compact.String(), nil
+}
@@ -0,0 +1,294 @@
+package composer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// composerRepositoryBindingType is the service binding type that provides a static Composer repository,
+// such as one generated by Satis
+const composerRepositoryBindingType = "composer-repository"
+
+// ComposerRepository is a static Composer repository in a local directory
+type ComposerRepository struct {
+	// Dir contains the packages.json
+	Dir string
+
+	// URL is the URL the repository was generated for, e.g. the Satis homepage. Dist URLs
+	// starting with it refer to files in Dir.
+	URL string
+}
+
+// composerRepositoryVersion is the subset of a package version in a Composer repository used by this buildpack
+type composerRepositoryVersion struct {
+	Version string `json:"version"`
+	Dist    struct {
+		URL       string `json:"url"`
+		Reference string `json:"reference"`
+	} `json:"dist"`
+	Source struct {
+		Reference string `json:"reference"`
+	} `json:"source"`
+}
+
+// resolveComposerRepository reads the `composer-repository` service binding. The repository is either the
+// binding itself, when it contains a packages.json, or the directory given by its `path` entry. An optional
+// `url` entry holds the URL the repository was generated for. It returns nil when there is no such binding.
+func resolveComposerRepository(logger scribe.Emitter, context packit.BuildContext, bindingResolver BindingResolver) (*ComposerRepository, error) {
+	bindings, err := bindingResolver.Resolve(composerRepositoryBindingType, "", context.Platform.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bindings) == 0 {
+		return nil, nil
+	}
+
+	if len(bindings) > 1 {
+		return nil, fmt.Errorf("found %d bindings of type '%s', only one is supported", len(bindings), composerRepositoryBindingType)
+	}
+	binding := bindings[0]
+
+	repository := ComposerRepository{Dir: binding.Path}
+
+	if entry, ok := binding.Entries["path"]; ok {
+		value, err := entry.ReadString()
+		if err != nil { // untested
+			return nil, err
+		}
+		repository.Dir = strings.TrimSpace(value)
+	}
+
+	if entry, ok := binding.Entries["url"]; ok {
+		value, err := entry.ReadString()
+		if err != nil { // untested
+			return nil, err
+		}
+		repository.URL = strings.TrimSuffix(strings.TrimSpace(value), "/")
+	}
+
+	if exists, err := fs.Exists(filepath.Join(repository.Dir, "packages.json")); err != nil { // untested
+		return nil, err
+	} else if !exists {
+		return nil, fmt.Errorf("no packages.json found in %s of binding '%s'", repository.Dir, binding.Name)
+	}
+
+	logger.Process("Using the local Composer repository at %s from service binding '%s'", repository.Dir, binding.Name)
+
+	return &repository, nil
+}
+
+// HomeConfig returns the config.json for COMPOSER_HOME that registers the repository and disables packagist.org
+func (r ComposerRepository) HomeConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"repositories": []interface{}{
+			map[string]interface{}{"type": "composer", "url": fmt.Sprintf("file://%s", r.Dir)},
+			map[string]interface{}{"packagist.org": false},
+		},
+	}
+}
+
+// DependencyMappings maps the locked packages in the given composer.lock files to their archives in the
+// repository, by `name@reference`. Packages that are missing from the repository are an error.
+func (r ComposerRepository) DependencyMappings(composerLockPaths ...string) (ComposerDependencyMappings, error) {
+	packagesJson, err := r.readJSON("packages.json")
+	if err != nil {
+		return nil, err
+	}
+
+	mappings := ComposerDependencyMappings{}
+	var missing []string
+	for _, composerLockPath := range composerLockPaths {
+		lock, err := ParseComposerLock(composerLockPath)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range lock.AllPackages() {
+			if p.Dist.Type == "path" || p.Type == "metapackage" {
+				continue
+			}
+
+			versions, err := r.versions(packagesJson, p.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			archive, ok := r.findArchive(versions, p)
+			if !ok {
+				missing = append(missing, fmt.Sprintf("%s %s", p.Name, p.Version))
+				continue
+			}
+			mappings[fmt.Sprintf("%s@%s", p.Name, p.Reference())] = archive
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("the following locked packages are missing from the local Composer repository at %s:\n  %s", r.Dir, strings.Join(missing, "\n  "))
+	}
+
+	return mappings, nil
+}
+
+// versions returns the versions of a package, from the `metadata-url` files of Composer 2 repositories,
+// or else from the `packages` and `includes` of packages.json
+func (r ComposerRepository) versions(packagesJson map[string]json.RawMessage, name string) ([]composerRepositoryVersion, error) {
+	var metadataURL string
+	if raw, ok := packagesJson["metadata-url"]; ok {
+		_ = json.Unmarshal(raw, &metadataURL)
+	}
+
+	if metadataURL != "" {
+		path := r.localPath(strings.ReplaceAll(metadataURL, "%package%", name))
+		metadata, err := r.readJSON(path)
+		if os.IsNotExist(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		var packages map[string][]map[string]interface{}
+		if err := json.Unmarshal(metadata["packages"], &packages); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		return expandComposerRepositoryVersions(packages[name])
+	}
+
+	documents := []map[string]json.RawMessage{packagesJson}
+
+	var includes map[string]json.RawMessage
+	if raw, ok := packagesJson["includes"]; ok {
+		_ = json.Unmarshal(raw, &includes)
+	}
+	for include := range includes {
+		document, err := r.readJSON(r.localPath(include))
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+
+	var versions []composerRepositoryVersion
+	for _, document := range documents {
+		raw, ok := document["packages"]
+		if !ok {
+			continue
+		}
+
+		var packages map[string]map[string]composerRepositoryVersion
+		if err := json.Unmarshal(raw, &packages); err != nil {
+			// an empty `packages` is encoded as an array
+			continue
+		}
+
+		for _, version := range packages[name] {
+			versions = append(versions, version)
+		}
+	}
+
+	return versions, nil
+}
+
+// expandComposerRepositoryVersions expands the versions of a package in Composer 2 metadata, which may be
+// minified: every version then only holds the fields that differ from the previous one.
+// https://github.com/composer/metadata-minifier
+func expandComposerRepositoryVersions(minified []map[string]interface{}) ([]composerRepositoryVersion, error) {
+	var versions []composerRepositoryVersion
+
+	expanded := map[string]interface{}{}
+	for _, item := range minified {
+		for key, value := range item {
+			if value == "__unset" {
+				delete(expanded, key)
+			} else {
+				expanded[key] = value
+			}
+		}
+
+		content, err := json.Marshal(expanded)
+		if err != nil { // untested
+			return nil, err
+		}
+
+		var version composerRepositoryVersion
+		if err := json.Unmarshal(content, &version); err != nil { // untested
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+// findArchive returns the local archive of the version matching the locked package, by reference or else by version
+func (r ComposerRepository) findArchive(versions []composerRepositoryVersion, p ComposerLockPackage) (string, bool) {
+	var match *composerRepositoryVersion
+	for i, version := range versions {
+		if p.Reference() != "" && (version.Dist.Reference == p.Reference() || version.Source.Reference == p.Reference()) {
+			match = &versions[i]
+			break
+		}
+		if match == nil && version.Version == p.Version {
+			match = &versions[i]
+		}
+	}
+
+	if match == nil || match.Dist.URL == "" {
+		return "", false
+	}
+
+	distURL := match.Dist.URL
+	if strings.Contains(distURL, "://") && !strings.HasPrefix(distURL, "file://") && (r.URL == "" || !strings.HasPrefix(distURL, r.URL+"/")) {
+		// the archive is not part of the local repository
+		return "", false
+	}
+
+	archive := r.localPath(distURL)
+	if !filepath.IsAbs(archive) {
+		archive = filepath.Join(r.Dir, archive)
+	}
+
+	if exists, err := fs.Exists(archive); err != nil || !exists {
+		return "", false
+	}
+
+	return archive, true
+}
+
+// localPath returns the path of a URL of the repository, relative to Dir unless it is a file:// URL
+func (r ComposerRepository) localPath(repositoryURL string) string {
+	if path, found := strings.CutPrefix(repositoryURL, "file://"); found {
+		return path
+	}
+	if r.URL != "" {
+		repositoryURL = strings.TrimPrefix(repositoryURL, r.URL)
+	}
+	return strings.TrimPrefix(repositoryURL, "/")
+}
+
+func (r ComposerRepository) readJSON(path string) (map[string]json.RawMessage, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.Dir, path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]json.RawMessage
+	err = json.Unmarshal(content, &document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return document, nil
+}
@@ -0,0 +1,120 @@
+package composer_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testComposerRepository(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		repositoryDir    string
+		composerLockPath string
+		repository       composer.ComposerRepository
+	)
+
+	it.Before(func() {
+		repositoryDir = t.TempDir()
+		Expect(os.MkdirAll(filepath.Join(repositoryDir, "dist", "org"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(repositoryDir, "dist", "org", "package-1.1.0.zip"), []byte("archive"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(repositoryDir, "dist", "org", "other-2.0.0.zip"), []byte("archive"), 0644)).To(Succeed())
+
+		composerLockPath = filepath.Join(t.TempDir(), "composer.lock")
+		Expect(os.WriteFile(composerLockPath, []byte(`{
+			"packages": [
+				{"name": "org/package", "version": "1.1.0", "source": {"reference": "ref-110"}},
+				{"name": "local/package", "version": "dev-main", "dist": {"type": "path", "url": "../local"}}
+			],
+			"packages-dev": [
+				{"name": "org/other", "version": "2.0.0", "dist": {"reference": "ref-200"}}
+			]
+		}`), 0644)).To(Succeed())
+
+		repository = composer.ComposerRepository{Dir: repositoryDir, URL: "https://satis.example.com"}
+	})
+
+	context("with Composer 2 metadata", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(repositoryDir, "packages.json"), []byte(`{
+				"packages": [],
+				"metadata-url": "/p2/%package%.json"
+			}`), 0644)).To(Succeed())
+
+			Expect(os.MkdirAll(filepath.Join(repositoryDir, "p2", "org"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(repositoryDir, "p2", "org", "package.json"), []byte(`{
+				"minified": "composer/2.0",
+				"packages": {"org/package": [
+					{"name": "org/package", "version": "1.2.0", "dist": {"url": "https://satis.example.com/dist/org/package-1.2.0.zip", "reference": "ref-120"}},
+					{"version": "1.1.0", "dist": {"url": "https://satis.example.com/dist/org/package-1.1.0.zip", "reference": "ref-110"}}
+				]}
+			}`), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(repositoryDir, "p2", "org", "other.json"), []byte(fmt.Sprintf(`{
+				"packages": {"org/other": [
+					{"name": "org/other", "version": "2.0.0", "dist": {"url": "file://%s/dist/org/other-2.0.0.zip", "reference": "ref-200"}}
+				]}
+			}`, repositoryDir)), 0644)).To(Succeed())
+		})
+
+		it("maps the locked packages to their archives", func() {
+			mappings, err := repository.DependencyMappings(composerLockPath, filepath.Join(repositoryDir, "missing.lock"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mappings).To(Equal(composer.ComposerDependencyMappings{
+				"org/package@ref-110": filepath.Join(repositoryDir, "dist", "org", "package-1.1.0.zip"),
+				"org/other@ref-200":   filepath.Join(repositoryDir, "dist", "org", "other-2.0.0.zip"),
+			}))
+		})
+	})
+
+	context("with includes", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(repositoryDir, "packages.json"), []byte(`{
+				"packages": {"org/other": {"2.0.0": {"version": "2.0.0", "dist": {"url": "dist/org/other-2.0.0.zip"}}}},
+				"includes": {"include/all$abc.json": {"sha1": "abc"}}
+			}`), 0644)).To(Succeed())
+
+			Expect(os.MkdirAll(filepath.Join(repositoryDir, "include"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(repositoryDir, "include", "all$abc.json"), []byte(`{
+				"packages": {"org/package": {"1.1.0": {"version": "1.1.0", "dist": {"url": "https://satis.example.com/dist/org/package-1.1.0.zip"}}}}
+			}`), 0644)).To(Succeed())
+		})
+
+		it("maps the locked packages to their archives", func() {
+			mappings, err := repository.DependencyMappings(composerLockPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mappings).To(Equal(composer.ComposerDependencyMappings{
+				"org/package@ref-110": filepath.Join(repositoryDir, "dist", "org", "package-1.1.0.zip"),
+				"org/other@ref-200":   filepath.Join(repositoryDir, "dist", "org", "other-2.0.0.zip"),
+			}))
+		})
+	})
+
+	context("when locked packages are missing from the repository", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(repositoryDir, "packages.json"), []byte(`{
+				"packages": {"org/package": {"1.1.0": {"version": "1.1.0", "dist": {"url": "https://packagist.example.com/org/package-1.1.0.zip"}}}}
+			}`), 0644)).To(Succeed())
+		})
+
+		it("lists them", func() {
+			_, err := repository.DependencyMappings(composerLockPath)
+			Expect(err).To(MatchError(fmt.Sprintf("the following locked packages are missing from the local Composer repository at %s:\n  org/package 1.1.0\n  org/other 2.0.0", repositoryDir)))
+		})
+	})
+
+	it("registers the repository and disables packagist.org", func() {
+		Expect(repository.HomeConfig()).To(Equal(map[string]interface{}{
+			"repositories": []interface{}{
+				map[string]interface{}{"type": "composer", "url": fmt.Sprintf("file://%s", repositoryDir)},
+				map[string]interface{}{"packagist.org": false},
+			},
+		}))
+	})
+}
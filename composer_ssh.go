@@ -0,0 +1,105 @@
+package composer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// composerSSHBindingType is the service binding type that provides SSH keys for `vcs` repositories
+const composerSSHBindingType = "composer-ssh"
+
+// setupComposerSSH writes the private keys and known hosts from `composer-ssh` service bindings
+// to a temporary directory outside of the layers, and returns a GIT_SSH_COMMAND that makes Git use
+// only those keys and verify host keys strictly against those known hosts. The returned cleanup
+// function removes the directory again.
+//
+// Each binding must contain an `ssh-privatekey` and a `known_hosts` entry.
+func setupComposerSSH(logger scribe.Emitter, context packit.BuildContext, bindingResolver BindingResolver) (env []string, cleanup func(), err error) {
+	cleanup = func() {}
+
+	bindings, err := bindingResolver.Resolve(composerSSHBindingType, "", context.Platform.Path)
+	if err != nil {
+		return nil, cleanup, err
+	}
+
+	if len(bindings) == 0 {
+		return nil, cleanup, nil
+	}
+
+	sshDir, err := os.MkdirTemp("", "composer-ssh")
+	if err != nil { // untested
+		return nil, cleanup, err
+	}
+	cleanup = func() {
+		if err := os.RemoveAll(sshDir); err != nil { // untested
+			logger.Process("WARNING: unable to remove the SSH configuration at %s: %s", sshDir, err)
+		}
+	}
+
+	knownHostsPath := filepath.Join(sshDir, "known_hosts")
+	command := []string{
+		"ssh",
+		"-F", "/dev/null",
+		"-o", "IdentitiesOnly=yes",
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", fmt.Sprintf("UserKnownHostsFile=%s", shellQuote(knownHostsPath)),
+	}
+
+	var knownHosts []string
+	for i, binding := range bindings {
+		logger.Process("Configuring SSH for Composer from service binding '%s'", binding.Name)
+
+		privateKeyEntry, ok := binding.Entries["ssh-privatekey"]
+		if !ok {
+			cleanup()
+			return nil, func() {}, fmt.Errorf("binding '%s' of type '%s' is missing the 'ssh-privatekey' entry", binding.Name, composerSSHBindingType)
+		}
+
+		knownHostsEntry, ok := binding.Entries["known_hosts"]
+		if !ok {
+			cleanup()
+			return nil, func() {}, fmt.Errorf("binding '%s' of type '%s' is missing the 'known_hosts' entry, which is required to verify host keys", binding.Name, composerSSHBindingType)
+		}
+
+		privateKey, err := privateKeyEntry.ReadString()
+		if err != nil { // untested
+			cleanup()
+			return nil, func() {}, err
+		}
+
+		content, err := knownHostsEntry.ReadString()
+		if err != nil { // untested
+			cleanup()
+			return nil, func() {}, err
+		}
+		knownHosts = append(knownHosts, strings.TrimRight(content, "\n"))
+
+		// ssh refuses keys without a trailing newline
+		privateKeyPath := filepath.Join(sshDir, fmt.Sprintf("id-%d", i))
+		err = os.WriteFile(privateKeyPath, []byte(strings.TrimRight(privateKey, "\n")+"\n"), 0600)
+		if err != nil { // untested
+			cleanup()
+			return nil, func() {}, err
+		}
+
+		command = append(command, "-i", shellQuote(privateKeyPath))
+	}
+
+	err = os.WriteFile(knownHostsPath, []byte(strings.Join(knownHosts, "\n")+"\n"), 0600)
+	if err != nil { // untested
+		cleanup()
+		return nil, func() {}, err
+	}
+
+	return []string{fmt.Sprintf("GIT_SSH_COMMAND=%s", strings.Join(command, " "))}, cleanup, nil
+}
+
+// shellQuote quotes value for the shell that Git runs GIT_SSH_COMMAND with
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,349 @@
+package composer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// composerVersionPattern matches the versions of tagged Composer packages, such as `v1.2.3`, `1.2.3.4`,
+// `1.2.0-beta.2`, `1.2.0RC1` or `1.2.3-p1`
+var composerVersionPattern = regexp.MustCompile(`^v?(\d+(?:\.\d+){0,3})(?:[._-]?(stable|beta|b|rc|alpha|a|patch|pl|p)((?:[.-]?\d+)*))?(?:[.-]?(dev))?$`)
+
+// composerStabilities ranks the stability suffixes of versions, as in Composer's VersionParser
+var composerStabilities = map[string]int{
+	"dev":    0,
+	"alpha":  1,
+	"a":      1,
+	"beta":   2,
+	"b":      2,
+	"rc":     3,
+	"stable": 4,
+	"":       4,
+	"patch":  5,
+	"pl":     5,
+	"p":      5,
+}
+
+// composerVersion is a parsed version of a tagged Composer package
+type composerVersion struct {
+	numbers   [4]int
+	stability int
+	suffix    []int
+}
+
+// parseComposerVersion parses a version the way Composer normalizes it: missing numbers are 0, and
+// build metadata is ignored. Branches, such as `dev-main` or `2.x-dev`, cannot be parsed.
+func parseComposerVersion(value string) (composerVersion, error) {
+	normalized := strings.ToLower(strings.TrimSpace(value))
+	normalized, _, _ = strings.Cut(normalized, "+")
+
+	matches := composerVersionPattern.FindStringSubmatch(normalized)
+	if matches == nil {
+		return composerVersion{}, fmt.Errorf("invalid version '%s'", value)
+	}
+
+	var version composerVersion
+	for i, number := range strings.Split(matches[1], ".") {
+		version.numbers[i], _ = strconv.Atoi(number)
+	}
+
+	version.stability = composerStabilities[matches[2]]
+	if matches[4] == "dev" && matches[2] == "" {
+		version.stability = composerStabilities["dev"]
+	}
+
+	for _, number := range strings.FieldsFunc(matches[3], func(r rune) bool { return r == '.' || r == '-' }) {
+		n, _ := strconv.Atoi(number)
+		version.suffix = append(version.suffix, n)
+	}
+
+	return version, nil
+}
+
+// compare returns -1, 0 or 1 when v is lower than, equal to or greater than other
+func (v composerVersion) compare(other composerVersion) int {
+	for i := range v.numbers {
+		if c := compareInts(v.numbers[i], other.numbers[i]); c != 0 {
+			return c
+		}
+	}
+
+	if c := compareInts(v.stability, other.stability); c != 0 {
+		return c
+	}
+
+	for i := 0; i < len(v.suffix) || i < len(other.suffix); i++ {
+		var a, b int
+		if i < len(v.suffix) {
+			a = v.suffix[i]
+		}
+		if i < len(other.suffix) {
+			b = other.suffix[i]
+		}
+		if c := compareInts(a, b); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// composerVersionConditionPattern matches a single condition of a version range, such as `>=1.2.0` or `<1.2.5`
+var composerVersionConditionPattern = regexp.MustCompile(`^(>=|<=|>|<|==|=|!=)?\s*(\S+)$`)
+
+// composerVersionCondition compares a version against a bound with an operator
+type composerVersionCondition struct {
+	operator string
+	bound    composerVersion
+}
+
+// parseComposerVersionCondition parses a condition such as `>=1.2.0`. A version without operator must match exactly.
+func parseComposerVersionCondition(condition string) (composerVersionCondition, error) {
+	matches := composerVersionConditionPattern.FindStringSubmatch(strings.TrimSpace(condition))
+	if matches == nil {
+		return composerVersionCondition{}, fmt.Errorf("invalid version condition '%s'", condition)
+	}
+
+	bound, err := parseComposerVersion(matches[2])
+	if err != nil {
+		return composerVersionCondition{}, fmt.Errorf("invalid version condition '%s': %w", condition, err)
+	}
+
+	return composerVersionCondition{operator: matches[1], bound: bound}, nil
+}
+
+func (c composerVersionCondition) satisfiedBy(version composerVersion) bool {
+	result := version.compare(c.bound)
+	switch c.operator {
+	case ">=":
+		return result >= 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	case "<":
+		return result < 0
+	case "!=":
+		return result != 0
+	default:
+		return result == 0
+	}
+}
+
+// matchesComposerVersionConditions returns whether the version satisfies all the conditions,
+// as listed for a branch in the FriendsOfPHP/security-advisories database
+func matchesComposerVersionConditions(version composerVersion, conditions []string) (bool, error) {
+	if len(conditions) == 0 {
+		return false, nil
+	}
+
+	for _, value := range conditions {
+		condition, err := parseComposerVersionCondition(value)
+		if err != nil {
+			return false, err
+		}
+
+		if !condition.satisfiedBy(version) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// ComposerVersionConstraint is a parsed Composer version constraint: a version matches when it satisfies
+// all the conditions of any of the alternatives
+//
+// https://getcomposer.org/doc/articles/versions.md#writing-version-constraints
+type ComposerVersionConstraint struct {
+	alternatives [][]composerVersionCondition
+}
+
+var (
+	composerConstraintAlternativePattern = regexp.MustCompile(`\|\|?`)
+	composerConstraintFlagPattern        = regexp.MustCompile(`@(dev|alpha|beta|rc|stable)\b`)
+	composerConstraintOperatorPattern    = regexp.MustCompile(`(>=|<=|>|<|!=|==|=|\^|~)\s+`)
+	composerConstraintHyphenPattern      = regexp.MustCompile(`^(\S+)\s+-\s+(\S+)$`)
+	composerConstraintWildcardPattern    = regexp.MustCompile(`^v?(\d+(?:\.\d+){0,2})\.[*x]$`)
+)
+
+// ParseComposerVersionConstraint parses constraints such as `^1.2`, `~1.2.3`, `1.2.*`, `>=1.0 <1.5`,
+// `1.0 - 2.0` and `^1.0 || ^2.0`
+func ParseComposerVersionConstraint(constraint string) (ComposerVersionConstraint, error) {
+	normalized := strings.ToLower(strings.TrimSpace(constraint))
+	normalized = composerConstraintFlagPattern.ReplaceAllString(normalized, "")
+	normalized = composerConstraintOperatorPattern.ReplaceAllString(normalized, "$1")
+
+	if normalized == "" {
+		return ComposerVersionConstraint{}, fmt.Errorf("invalid version constraint '%s'", constraint)
+	}
+
+	var parsed ComposerVersionConstraint
+	for _, alternative := range composerConstraintAlternativePattern.Split(normalized, -1) {
+		alternative = strings.TrimSpace(alternative)
+
+		var conditions []composerVersionCondition
+		if matches := composerConstraintHyphenPattern.FindStringSubmatch(alternative); matches != nil {
+			lower, err := parseComposerVersionCondition(">=" + matches[1])
+			if err != nil {
+				return ComposerVersionConstraint{}, fmt.Errorf("invalid version constraint '%s': %w", constraint, err)
+			}
+			upper, parts, err := parseComposerVersionParts(matches[2])
+			if err != nil {
+				return ComposerVersionConstraint{}, fmt.Errorf("invalid version constraint '%s': %w", constraint, err)
+			}
+
+			// a partial upper bound is a wildcard, e.g. 1.0 - 2.0 is <2.1 and 1 - 2 is <3.0
+			if parts >= 3 || upper.stability != composerStabilities["stable"] {
+				conditions = []composerVersionCondition{lower, {operator: "<=", bound: upper}}
+			} else {
+				conditions = []composerVersionCondition{lower, {operator: "<", bound: upper.bump(parts - 1)}}
+			}
+		} else {
+			atoms := strings.FieldsFunc(alternative, func(r rune) bool { return r == ',' || r == ' ' })
+			if len(atoms) == 0 {
+				return ComposerVersionConstraint{}, fmt.Errorf("invalid version constraint '%s'", constraint)
+			}
+
+			for _, atom := range atoms {
+				atomConditions, err := parseComposerConstraintAtom(atom)
+				if err != nil {
+					return ComposerVersionConstraint{}, fmt.Errorf("invalid version constraint '%s': %w", constraint, err)
+				}
+				conditions = append(conditions, atomConditions...)
+			}
+		}
+
+		parsed.alternatives = append(parsed.alternatives, conditions)
+	}
+
+	return parsed, nil
+}
+
+// parseComposerConstraintAtom parses a single part of a constraint into the conditions it stands for
+func parseComposerConstraintAtom(atom string) ([]composerVersionCondition, error) {
+	if atom == "*" || atom == "x" {
+		return nil, nil
+	}
+
+	if matches := composerConstraintWildcardPattern.FindStringSubmatch(atom); matches != nil {
+		lower, parts, err := parseComposerVersionParts(matches[1])
+		if err != nil {
+			return nil, err
+		}
+		lower.stability = composerStabilities["dev"]
+		return []composerVersionCondition{
+			{operator: ">=", bound: lower},
+			{operator: "<", bound: lower.bump(parts - 1)},
+		}, nil
+	}
+
+	if value, found := strings.CutPrefix(atom, "^"); found {
+		lower, parts, err := parseComposerVersionParts(value)
+		if err != nil {
+			return nil, err
+		}
+
+		// the first non-zero part may not change, e.g. ^1.2 is <2.0 and ^0.3 is <0.4
+		position := parts - 1
+		for i := 0; i < parts; i++ {
+			if lower.numbers[i] != 0 {
+				position = i
+				break
+			}
+		}
+
+		return []composerVersionCondition{
+			{operator: ">=", bound: lower},
+			{operator: "<", bound: lower.bump(position)},
+		}, nil
+	}
+
+	if value, found := strings.CutPrefix(atom, "~"); found {
+		lower, parts, err := parseComposerVersionParts(value)
+		if err != nil {
+			return nil, err
+		}
+
+		// the last given part may change, e.g. ~1.2 is <2.0 and ~1.2.3 is <1.3
+		position := parts - 2
+		if position < 0 {
+			position = 0
+		}
+
+		return []composerVersionCondition{
+			{operator: ">=", bound: lower},
+			{operator: "<", bound: lower.bump(position)},
+		}, nil
+	}
+
+	condition, err := parseComposerVersionCondition(atom)
+	if err != nil {
+		return nil, err
+	}
+
+	return []composerVersionCondition{condition}, nil
+}
+
+// parseComposerVersionParts parses a version and returns how many of its numbers were given
+func parseComposerVersionParts(value string) (composerVersion, int, error) {
+	version, err := parseComposerVersion(value)
+	if err != nil {
+		return composerVersion{}, 0, err
+	}
+
+	numbers, _, _ := strings.Cut(strings.TrimPrefix(value, "v"), "-")
+	parts := len(strings.Split(numbers, "."))
+	if parts > len(version.numbers) {
+		parts = len(version.numbers)
+	}
+
+	return version, parts, nil
+}
+
+// bump returns the lowest dev version after all versions sharing the numbers up to the given position
+func (v composerVersion) bump(position int) composerVersion {
+	var bumped composerVersion
+	copy(bumped.numbers[:position], v.numbers[:position])
+	bumped.numbers[position] = v.numbers[position] + 1
+	bumped.stability = composerStabilities["dev"]
+	return bumped
+}
+
+// Matches returns whether the version satisfies the constraint. Versions that cannot be parsed,
+// such as branches, never do.
+func (c ComposerVersionConstraint) Matches(version string) bool {
+	parsed, err := parseComposerVersion(version)
+	if err != nil {
+		return false
+	}
+
+	for _, conditions := range c.alternatives {
+		satisfied := true
+		for _, condition := range conditions {
+			if !condition.satisfiedBy(parsed) {
+				satisfied = false
+				break
+			}
+		}
+
+		if satisfied {
+			return true
+		}
+	}
+
+	return false
+}
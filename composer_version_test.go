@@ -0,0 +1,82 @@
+package composer_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/composer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testComposerVersionConstraint(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	it("matches versions the way Composer does", func() {
+		for _, c := range []struct {
+			constraint string
+			matching   []string
+			other      []string
+		}{
+			{"1.2.3", []string{"1.2.3", "v1.2.3.0"}, []string{"1.2.4", "1.2.3-beta1"}},
+			{">=1.2, <1.2.5", []string{"1.2.0", "1.2.4"}, []string{"1.1.9", "1.2.5"}},
+			{">= 1.2 < 1.2.5", []string{"1.2.0", "1.2.4"}, []string{"1.2.5"}},
+			{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"1.2.2", "2.0.0", "2.0.0-beta1"}},
+			{"^0.3", []string{"0.3.0", "0.3.9"}, []string{"0.4.0"}},
+			{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+			{"~1.2", []string{"1.2.0", "1.9.9"}, []string{"2.0.0"}},
+			{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0"}},
+			{"1.2.*", []string{"1.2.0", "1.2.99", "1.2.0-rc1"}, []string{"1.3.0", "1.1.9"}},
+			{"1.0 - 2.0", []string{"1.0.0", "2.0.0", "2.0.9"}, []string{"0.9.9", "2.1.0", "2.1.0-beta1"}},
+			{"1 - 2", []string{"1.0.0", "2.9.9"}, []string{"3.0.0"}},
+			{"1.0.0 - 2.1.0", []string{"2.1.0"}, []string{"2.1.1"}},
+			{"1.0 - 2.0.0-beta2", []string{"2.0.0-beta1", "2.0.0-beta2"}, []string{"2.0.0-rc1", "2.0.0"}},
+			{"^1.0 || ^3.0", []string{"1.5.0", "3.1.0"}, []string{"2.0.0"}},
+			{"^2.0@dev", []string{"2.1.0"}, []string{"3.0.0"}},
+			{"*", []string{"0.1.0", "9.9.9"}, []string{"dev-main"}},
+		} {
+			constraint, err := composer.ParseComposerVersionConstraint(c.constraint)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, version := range c.matching {
+				Expect(constraint.Matches(version)).To(BeTrue(), "%s should match %s", version, c.constraint)
+			}
+			for _, version := range c.other {
+				Expect(constraint.Matches(version)).To(BeFalse(), "%s should not match %s", version, c.constraint)
+			}
+		}
+	})
+
+	it("orders versions the way Composer does", func() {
+		for _, c := range []struct {
+			a, b     string
+			expected string
+		}{
+			{"1.0.0", "1.0.0", "=="},
+			{"v1.0", "1.0.0.0", "=="},
+			{"1.0.0", "1.0.1", "<"},
+			{"1.10.0", "1.9.9", ">"},
+			{"2", "1.99", ">"},
+			{"1.0.0-dev", "1.0.0-alpha1", "<"},
+			{"1.0.0-alpha2", "1.0.0-beta1", "<"},
+			{"1.0.0-beta.2", "1.0.0-beta10", "<"},
+			{"1.0.0RC1", "1.0.0", "<"},
+			{"1.0.0", "1.0.0-p1", "<"},
+			{"1.0.0+build.5", "1.0.0", "=="},
+		} {
+			for _, operator := range []string{"<", "==", ">"} {
+				constraint, err := composer.ParseComposerVersionConstraint(operator + c.b)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(constraint.Matches(c.a)).To(Equal(operator == c.expected), "%s %s %s", c.a, operator, c.b)
+			}
+		}
+	})
+
+	it("returns an error for invalid constraints", func() {
+		_, err := composer.ParseComposerVersionConstraint("^one")
+		Expect(err).To(MatchError("invalid version constraint '^one': invalid version 'one'"))
+
+		_, err = composer.ParseComposerVersionConstraint(" ")
+		Expect(err).To(MatchError("invalid version constraint ' '"))
+	})
+}
@@ -5,11 +5,39 @@ const (
 	ComposerGlobalLayerName   = "composer-global"
 	ComposerPhpIniLayerName   = "composer-php-ini"
 
+	// ComposerPackagesDevLayerName holds the packages including `require-dev` when BP_COMPOSER_INSTALL_DEV_FOR_BUILD is set
+	ComposerPackagesDevLayerName = "composer-packages-dev"
+
+	// ComposerCACertificatesLayerName holds the CA bundle used by Composer when additional CA certificates are configured
+	ComposerCACertificatesLayerName = "composer-ca-certificates"
+
+	// ComposerMirrorLayerName holds copies of composer.json and composer.lock with the dist URLs rewritten to mirrors
+	ComposerMirrorLayerName = "composer-mirror"
+
+	// ComposerAttributionLayerName holds the license texts of the installed packages, and is available at launch
+	ComposerAttributionLayerName = "composer-attribution"
+
+	// ComposerAuditLayerName holds the report of `composer audit`, and is only available during the build
+	ComposerAuditLayerName = "composer-audit"
+
+	// ComposerHardenedLayerName holds the copy of composer.json that restricts the plugins and scripts run in hardened mode
+	ComposerHardenedLayerName = "composer-hardened"
+
+	// ComposerCacheLayerName holds the Composer cache with the downloaded archives when BP_COMPOSER_VERIFY_DIST is set,
+	// so that they can be checked against composer.lock. It is cached between builds.
+	ComposerCacheLayerName = "composer-cache"
+
+	// ComposerPackagesLayerMetadataVersion is the schema version of the composer-packages layer metadata.
+	// Increment it whenever the metadata format changes. Cached layers with metadata of another version
+	// are rebuilt.
+	ComposerPackagesLayerMetadataVersion = 2
+
 	// Autoloader Suffix
 	ComposerAutoloaderSuffix = "PaketoDefaultAutoloaderSuffix"
 
 	// Dependencies
 	ComposerDependency         = "composer"
+	ComposerGlobalDependency   = "composer-global"
 	ComposerPackagesDependency = "composer-packages"
 	PhpDependency              = "php"
 
@@ -17,6 +45,13 @@ const (
 	DefaultComposerJsonPath = "composer.json"
 	DefaultComposerLockPath = "composer.lock"
 
+	// ComposerLicensePolicyFile is the file in the application with the license policy for the locked packages
+	ComposerLicensePolicyFile = ".composer-license-policy.toml"
+
+	// ComposerGlobalManifestDir is the directory in the application containing the composer.json
+	// (and optionally composer.lock) of the global packages, as an alternative to BP_COMPOSER_INSTALL_GLOBAL
+	ComposerGlobalManifestDir = ".composer-global"
+
 	// Environment Variables
 
 	// Composer can set the filename for `composer.json` to something else
@@ -31,10 +66,95 @@ const (
 	// This is typically so that they will be available during `composer` scripts
 	BpComposerInstallGlobal = "BP_COMPOSER_INSTALL_GLOBAL"
 
+	// BpComposerGlobalLayerTypes is a comma-separated list of "build" and/or "launch", to make the global
+	// Composer packages available to later buildpacks and/or at launch, with their vendor/bin on the PATH
+	BpComposerGlobalLayerTypes = "BP_COMPOSER_GLOBAL_LAYER_TYPES"
+
 	// BpComposerInstallOptions is a list of options to be provided to `composer install`
 	// These will be parsed using the shellwords library https://github.com/mattn/go-shellwords
 	BpComposerInstallOptions = "BP_COMPOSER_INSTALL_OPTIONS"
 
+	// BpComposerInstallDevForBuild can be set to "true" to install the `require-dev` packages into a separate
+	// layer that is only available during the build, while the packages shipped at launch are installed with `--no-dev`
+	BpComposerInstallDevForBuild = "BP_COMPOSER_INSTALL_DEV_FOR_BUILD"
+
+	// BpComposerVendorRestore selects how the vendor directory is moved between the workspace and the
+	// composer-packages layer: "copy" (default) or "hardlink" (falls back to copying)
+	BpComposerVendorRestore = "BP_COMPOSER_VENDOR_RESTORE"
+
+	// BpComposerCACerts is a list of PEM files with additional CA certificates for Composer, separated by ':'.
+	// Relative paths are resolved against the application directory.
+	BpComposerCACerts = "BP_COMPOSER_CA_CERTS"
+
+	// BpComposerMirror is a comma-separated list of `prefix=mirror` URL pairs. Downloads starting with a prefix
+	// are fetched from the mirror instead, and a mirror of https://repo.packagist.org replaces packagist.org.
+	BpComposerMirror = "BP_COMPOSER_MIRROR"
+
+	// BpComposerAudit selects what to do with security advisories found by `composer audit` after the install:
+	// "off" (default), "warn" or "fail"
+	BpComposerAudit = "BP_COMPOSER_AUDIT"
+
+	// BpComposerAuditSeverity is the lowest severity ("low", "medium", "high" or "critical") of advisories that
+	// fail the build when BP_COMPOSER_AUDIT is "fail". It defaults to "low".
+	BpComposerAuditSeverity = "BP_COMPOSER_AUDIT_SEVERITY"
+
+	// BpComposerAuditIgnore is a comma-separated list of advisory IDs or CVEs that never fail the build
+	BpComposerAuditIgnore = "BP_COMPOSER_AUDIT_IGNORE"
+
+	// BpComposerAuditDatabase is a directory with a FriendsOfPHP/security-advisories database to check packages
+	// against instead of running `composer audit`. Relative paths are resolved against the application directory.
+	BpComposerAuditDatabase = "BP_COMPOSER_AUDIT_DATABASE"
+
+	// BpComposerLicenseAllow is a comma-separated list of the SPDX license identifiers that locked packages may have.
+	// A `*` matches any part of an identifier, e.g. `BSD-*`.
+	BpComposerLicenseAllow = "BP_COMPOSER_LICENSE_ALLOW"
+
+	// BpComposerLicenseDeny is a comma-separated list of the SPDX license identifiers that locked packages must not have
+	BpComposerLicenseDeny = "BP_COMPOSER_LICENSE_DENY"
+
+	// BpComposerLicenseExceptions is a comma-separated list of packages that are exempt from the license policy
+	BpComposerLicenseExceptions = "BP_COMPOSER_LICENSE_EXCEPTIONS"
+
+	// BpComposerAttribution can be set to "true" to write the license texts of the installed packages into
+	// attribution.txt and attribution.json in the composer-attribution layer, which is available at launch
+	BpComposerAttribution = "BP_COMPOSER_ATTRIBUTION"
+
+	// BpComposerAbandoned selects what to do with the packages that composer.lock marks as abandoned:
+	// "ignore", "warn" (default) or "fail"
+	BpComposerAbandoned = "BP_COMPOSER_ABANDONED"
+
+	// BpComposerDeny is a list of packages that must not be installed, separated by newlines or ';'. Each rule is
+	// either `vendor/package` or `vendor/package:constraint` to deny only the versions matching a Composer constraint.
+	BpComposerDeny = "BP_COMPOSER_DENY"
+
+	// BpComposerHardened can be set to "true" to install with `--no-plugins --no-scripts`, and then only run
+	// the plugins and script events that are allowed
+	BpComposerHardened = "BP_COMPOSER_HARDENED"
+
+	// BpComposerAllowedPlugins is a comma-separated list of plugins that may run in hardened mode, in addition to
+	// those allowed by `config.allow-plugins` in composer.json. `*` matches any part of a name.
+	BpComposerAllowedPlugins = "BP_COMPOSER_ALLOWED_PLUGINS"
+
+	// BpComposerAllowedScripts is a comma-separated list of script events, such as "post-autoload-dump", whose
+	// scripts may run in hardened mode
+	BpComposerAllowedScripts = "BP_COMPOSER_ALLOWED_SCRIPTS"
+
+	// BpComposerHermeticEnv can be set to "true" to only pass a base set of variables of the build environment
+	// to Composer, together with those on BP_COMPOSER_ENV_ALLOWLIST and the variables controlled by the buildpack
+	BpComposerHermeticEnv = "BP_COMPOSER_HERMETIC_ENV"
+
+	// BpComposerEnvAllowlist is a comma-separated list of variables passed to Composer in hermetic mode,
+	// where `*` matches any part of a name, e.g. "APP_*"
+	BpComposerEnvAllowlist = "BP_COMPOSER_ENV_ALLOWLIST"
+
+	// BpComposerVerifyDist can be set to "true" to check the downloaded archives against the dist shasum in
+	// composer.lock, and the installed packages against the names, versions and references in composer.lock
+	BpComposerVerifyDist = "BP_COMPOSER_VERIFY_DIST"
+
+	// BpComposerProvenanceLabel can be set to "true" to add the provenance of the vendor directory to the
+	// app image as the label "io.paketo.composer.provenance"
+	BpComposerProvenanceLabel = "BP_COMPOSER_PROVENANCE_LABEL"
+
 	// PhpExtensionDir is the directory containing PHP extensions.
 	// It is set by the Paketo buildpack `php-dist`
 	PhpExtensionDir = "PHP_EXTENSION_DIR"
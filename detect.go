@@ -59,21 +59,38 @@ func Detect(logEmitter scribe.Emitter, phpVersionResolver PhpVersionResolverInte
 			}
 		}
 
+		requires := []packit.BuildPlanRequirement{
+			{
+				Name: ComposerDependency,
+				Metadata: BuildPlanMetadata{
+					Build: true,
+				},
+			},
+			phpRequirement,
+		}
+
+		// composer-global is only provided when a later buildpack requires it, since the lifecycle rejects
+		// a group with a provision that no buildpack requires
 		return packit.DetectResult{
 			Plan: packit.BuildPlan{
 				Provides: []packit.BuildPlanProvision{
 					{
 						Name: ComposerPackagesDependency,
 					},
+					{
+						Name: ComposerGlobalDependency,
+					},
 				},
-				Requires: []packit.BuildPlanRequirement{
+				Requires: requires,
+				Or: []packit.BuildPlan{
 					{
-						Name: ComposerDependency,
-						Metadata: BuildPlanMetadata{
-							Build: true,
+						Provides: []packit.BuildPlanProvision{
+							{
+								Name: ComposerPackagesDependency,
+							},
 						},
+						Requires: requires,
 					},
-					phpRequirement,
 				},
 			},
 		}, nil
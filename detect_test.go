@@ -55,7 +55,7 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 			Expect(os.WriteFile(filepath.Join(workingDir, "composer.json"), []byte("{}"), 0644)).To(Succeed())
 		})
 
-		it(`requires "composer" and "php" and provides "composer-packages"`, func() {
+		it(`requires "composer" and "php" and provides "composer-packages" and "composer-global", or only "composer-packages"`, func() {
 			detectResult, err := detect(packit.DetectContext{WorkingDir: workingDir})
 			Expect(err).NotTo(HaveOccurred())
 
@@ -64,6 +64,9 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 					{
 						Name: composer.ComposerPackagesDependency,
 					},
+					{
+						Name: composer.ComposerGlobalDependency,
+					},
 				},
 				Requires: []packit.BuildPlanRequirement{
 					{
@@ -79,6 +82,29 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 						},
 					},
 				},
+				Or: []packit.BuildPlan{
+					{
+						Provides: []packit.BuildPlanProvision{
+							{
+								Name: composer.ComposerPackagesDependency,
+							},
+						},
+						Requires: []packit.BuildPlanRequirement{
+							{
+								Name: "composer",
+								Metadata: composer.BuildPlanMetadata{
+									Build: true,
+								},
+							},
+							{
+								Name: "php",
+								Metadata: composer.BuildPlanMetadata{
+									Build: true,
+								},
+							},
+						},
+					},
+				},
 			}))
 
 			Expect(phpVersionResolver.ResolveCall.Receives.ComposerJsonPath).To(Equal(filepath.Join(workingDir, "composer.json")))
@@ -100,6 +126,9 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 						{
 							Name: composer.ComposerPackagesDependency,
 						},
+						{
+							Name: composer.ComposerGlobalDependency,
+						},
 					},
 					Requires: []packit.BuildPlanRequirement{
 						{
@@ -117,6 +146,31 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 							},
 						},
 					},
+					Or: []packit.BuildPlan{
+						{
+							Provides: []packit.BuildPlanProvision{
+								{
+									Name: composer.ComposerPackagesDependency,
+								},
+							},
+							Requires: []packit.BuildPlanRequirement{
+								{
+									Name: "composer",
+									Metadata: composer.BuildPlanMetadata{
+										Build: true,
+									},
+								},
+								{
+									Name: "php",
+									Metadata: composer.BuildPlanMetadata{
+										Build:         true,
+										Version:       "php-version-from-resolver",
+										VersionSource: "php-version-source-from-resolver",
+									},
+								},
+							},
+						},
+					},
 				}))
 			})
 		})
@@ -170,7 +224,7 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 				Expect(os.WriteFile(filepath.Join(workingDir, "other", "location", "composer.json"), []byte("{}"), os.ModePerm)).To(Succeed())
 			})
 
-			it(`requires "composer" and "php" and provides "composer-packages"`, func() {
+			it(`requires "composer" and "php" and provides "composer-packages" and "composer-global", or only "composer-packages"`, func() {
 				detectResult, err := detect(packit.DetectContext{WorkingDir: workingDir})
 				Expect(err).NotTo(HaveOccurred())
 
@@ -179,6 +233,9 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 						{
 							Name: composer.ComposerPackagesDependency,
 						},
+						{
+							Name: composer.ComposerGlobalDependency,
+						},
 					},
 					Requires: []packit.BuildPlanRequirement{
 						{
@@ -194,6 +251,29 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 							},
 						},
 					},
+					Or: []packit.BuildPlan{
+						{
+							Provides: []packit.BuildPlanProvision{
+								{
+									Name: composer.ComposerPackagesDependency,
+								},
+							},
+							Requires: []packit.BuildPlanRequirement{
+								{
+									Name: "composer",
+									Metadata: composer.BuildPlanMetadata{
+										Build: true,
+									},
+								},
+								{
+									Name: "php",
+									Metadata: composer.BuildPlanMetadata{
+										Build: true,
+									},
+								},
+							},
+						},
+					},
 				}))
 
 				Expect(phpVersionResolver.ResolveCall.Receives.ComposerJsonPath).To(Equal(filepath.Join(workingDir, "other", "location", "composer.json")))
@@ -2,6 +2,7 @@ package composer
 
 import (
 	"os"
+	"slices"
 
 	"github.com/mattn/go-shellwords"
 )
@@ -14,25 +15,55 @@ func NewComposerInstallOptions() InstallOptions {
 
 // Determine will generate the list of options for `composer install`
 // https://getcomposer.org/doc/03-cli.md#install-i
-func (InstallOptions) Determine() []string {
+//
+// The options requested by downstream buildpacks through the `composer-packages` plan metadata
+// are applied on top of the defaults. Options given explicitly in BP_COMPOSER_INSTALL_OPTIONS
+// are never removed, so they take precedence.
+func (InstallOptions) Determine(planMetadata ComposerPackagesPlanMetadata) []string {
+	var options []string
 	if installOptionsFromEnv, exists := os.LookupEnv(BpComposerInstallOptions); !exists {
-		return []string{
+		options = []string{
 			"--no-progress",
-			"--no-dev",
+		}
+		if !planMetadata.Dev {
+			options = append(options, "--no-dev")
 		}
 	} else if installOptionsFromEnv == "" {
-		return []string{
+		options = []string{
 			"--no-progress",
 		}
 	} else {
 		parsedOptionsFromEnv, err := shellwords.Parse(installOptionsFromEnv)
 		if err != nil {
-			return []string{
+			options = []string{
 				"--no-progress",
 				installOptionsFromEnv,
 			}
+		} else {
+			options = append([]string{"--no-progress"}, parsedOptionsFromEnv...)
 		}
+	}
 
-		return append([]string{"--no-progress"}, parsedOptionsFromEnv...)
+	switch planMetadata.AutoloadMode {
+	case AutoloadModeOptimized:
+		options = appendOption(options, "--optimize-autoloader", "-o")
+	case AutoloadModeClassmapAuthoritative:
+		options = appendOption(options, "--classmap-authoritative", "-a")
+	}
+
+	if planMetadata.Scripts != nil && !*planMetadata.Scripts {
+		options = appendOption(options, "--no-scripts")
+	}
+
+	return options
+}
+
+// appendOption appends the option unless it, or one of its aliases, is already present
+func appendOption(options []string, option string, aliases ...string) []string {
+	for _, o := range append([]string{option}, aliases...) {
+		if slices.Contains(options, o) {
+			return options
+		}
 	}
+	return append(options, option)
 }
@@ -27,7 +27,7 @@ func testComposerInstallOptions(t *testing.T, context spec.G, it spec.S) {
 
 	context("when BP_COMPOSER_INSTALL_OPTIONS is not set", func() {
 		it("should return default options", func() {
-			Expect(options.Determine()).To(Equal([]string{
+			Expect(options.Determine(composer.ComposerPackagesPlanMetadata{})).To(Equal([]string{
 				"--no-progress",
 				"--no-dev",
 			}))
@@ -40,7 +40,7 @@ func testComposerInstallOptions(t *testing.T, context spec.G, it spec.S) {
 		})
 
 		it("should return --no-progress only", func() {
-			Expect(options.Determine()).To(Equal([]string{
+			Expect(options.Determine(composer.ComposerPackagesPlanMetadata{})).To(Equal([]string{
 				"--no-progress",
 			}))
 		})
@@ -52,7 +52,7 @@ func testComposerInstallOptions(t *testing.T, context spec.G, it spec.S) {
 		})
 
 		it("should return those values as individual args", func() {
-			Expect(options.Determine()).To(Equal([]string{
+			Expect(options.Determine(composer.ComposerPackagesPlanMetadata{})).To(Equal([]string{
 				"--no-progress",
 				"--foo=bar",
 				"-v",
@@ -61,13 +61,46 @@ func testComposerInstallOptions(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when downstream buildpacks request options through plan metadata", func() {
+		var scripts bool
+
+		it("should apply them on top of the default options", func() {
+			Expect(options.Determine(composer.ComposerPackagesPlanMetadata{
+				Dev:          true,
+				AutoloadMode: composer.AutoloadModeClassmapAuthoritative,
+				Scripts:      &scripts,
+			})).To(Equal([]string{
+				"--no-progress",
+				"--classmap-authoritative",
+				"--no-scripts",
+			}))
+		})
+
+		context("when BP_COMPOSER_INSTALL_OPTIONS has options", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_COMPOSER_INSTALL_OPTIONS", "--no-dev -o")).To(Succeed())
+			})
+
+			it("should keep the options from the environment", func() {
+				Expect(options.Determine(composer.ComposerPackagesPlanMetadata{
+					Dev:          true,
+					AutoloadMode: composer.AutoloadModeOptimized,
+				})).To(Equal([]string{
+					"--no-progress",
+					"--no-dev",
+					"-o",
+				}))
+			})
+		})
+	})
+
 	context("when BP_COMPOSER_INSTALL_OPTIONS has invalid options", func() {
 		it.Before(func() {
 			Expect(os.Setenv("BP_COMPOSER_INSTALL_OPTIONS", "invalid'option for composer")).To(Succeed())
 		})
 
 		it("should return those values as one single arg", func() {
-			Expect(options.Determine()).To(Equal([]string{
+			Expect(options.Determine(composer.ComposerPackagesPlanMetadata{})).To(Equal([]string{
 				"--no-progress",
 				"invalid'option for composer",
 			}))
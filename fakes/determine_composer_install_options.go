@@ -2,25 +2,31 @@ package fakes
 
 import (
 	"sync"
+
+	"github.com/paketo-buildpacks/composer"
 )
 
 type DetermineComposerInstallOptions struct {
 	DetermineCall struct {
 		mutex     sync.Mutex
 		CallCount int
-		Returns   struct {
+		Receives  struct {
+			PlanMetadata composer.ComposerPackagesPlanMetadata
+		}
+		Returns struct {
 			StringSlice []string
 		}
-		Stub func() []string
+		Stub func(composer.ComposerPackagesPlanMetadata) []string
 	}
 }
 
-func (f *DetermineComposerInstallOptions) Determine() []string {
+func (f *DetermineComposerInstallOptions) Determine(param1 composer.ComposerPackagesPlanMetadata) []string {
 	f.DetermineCall.mutex.Lock()
 	defer f.DetermineCall.mutex.Unlock()
 	f.DetermineCall.CallCount++
+	f.DetermineCall.Receives.PlanMetadata = param1
 	if f.DetermineCall.Stub != nil {
-		return f.DetermineCall.Stub()
+		return f.DetermineCall.Stub(param1)
 	}
 	return f.DetermineCall.Returns.StringSlice
 }
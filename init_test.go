@@ -12,6 +12,12 @@ func TestUnitComposer(t *testing.T) {
 	suite("Detect", testDetect, spec.Sequential())
 	suite("Build", testBuild, spec.Sequential())
 	suite("InstallOptions", testComposerInstallOptions)
+	suite("ComposerPackagesPlanMetadata", testComposerPackagesPlanMetadata)
+	suite("ComposerLicensePolicy", testComposerLicensePolicy)
+	suite("ComposerAdvisoryDatabase", testComposerAdvisoryDatabase)
+	suite("ComposerRepository", testComposerRepository)
+	suite("ComposerVersionConstraint", testComposerVersionConstraint)
 	suite("PhpVersionResolver", testPhpVersionResolver, spec.Sequential())
+	suite("SecretMaskingWriter", testSecretMaskingWriter)
 	suite.Run(t)
 }
@@ -0,0 +1,95 @@
+package composer
+
+import "sort"
+
+// installedPackage is the record of a locked package stored in the
+// composer-packages layer metadata under the "packages" key, so that a later
+// build can tell which packages are unchanged when `composer.lock` changes.
+type installedPackage struct {
+	Name      string
+	Version   string
+	Reference string
+	Shasum    string
+}
+
+func installedPackagesFromLock(lock ComposerLock) []installedPackage {
+	var packages []installedPackage
+	for _, p := range lock.AllPackages() {
+		packages = append(packages, installedPackage{
+			Name:      p.Name,
+			Version:   p.Version,
+			Reference: p.Reference(),
+			Shasum:    p.Dist.Shasum,
+		})
+	}
+	return packages
+}
+
+// installedPackagesMetadata converts the packages into a form that is written
+// to the layer metadata as an array of tables.
+func installedPackagesMetadata(packages []installedPackage) []map[string]interface{} {
+	var metadata []map[string]interface{}
+	for _, p := range packages {
+		metadata = append(metadata, map[string]interface{}{
+			"name":      p.Name,
+			"version":   p.Version,
+			"reference": p.Reference,
+			"shasum":    p.Shasum,
+		})
+	}
+	return metadata
+}
+
+// installedPackagesFromMetadata reads the "packages" layer metadata, either as
+// written by installedPackagesMetadata or as decoded from the layer TOML file.
+func installedPackagesFromMetadata(value interface{}) []installedPackage {
+	var tables []map[string]interface{}
+	switch v := value.(type) {
+	case []map[string]interface{}:
+		tables = v
+	case []interface{}:
+		for _, item := range v {
+			if table, ok := item.(map[string]interface{}); ok {
+				tables = append(tables, table)
+			}
+		}
+	}
+
+	var packages []installedPackage
+	for _, table := range tables {
+		name, _ := table["name"].(string)
+		version, _ := table["version"].(string)
+		reference, _ := table["reference"].(string)
+		shasum, _ := table["shasum"].(string)
+		packages = append(packages, installedPackage{
+			Name:      name,
+			Version:   version,
+			Reference: reference,
+			Shasum:    shasum,
+		})
+	}
+	return packages
+}
+
+// diffInstalledPackages compares the previously installed packages with the
+// packages that are about to be installed, returning the sorted names of the
+// unchanged packages and of those that are new or changed.
+func diffInstalledPackages(previous, current []installedPackage) (unchanged, changed []string) {
+	previousByName := map[string]installedPackage{}
+	for _, p := range previous {
+		previousByName[p.Name] = p
+	}
+
+	for _, p := range current {
+		if previousByName[p.Name] == p {
+			unchanged = append(unchanged, p.Name)
+		} else {
+			changed = append(changed, p.Name)
+		}
+	}
+
+	sort.Strings(unchanged)
+	sort.Strings(changed)
+
+	return unchanged, changed
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/paketo-buildpacks/packit/v2/pexec"
 	"github.com/paketo-buildpacks/packit/v2/sbom"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
 )
 
 type Generator struct{}
@@ -27,6 +28,9 @@ func main() {
 	installExec := pexec.NewExecutable("composer")
 	globalExec := pexec.NewExecutable("composer")
 	checkPlatformReqsExec := pexec.NewExecutable("composer")
+	auditExec := pexec.NewExecutable("composer")
+	runScriptExec := pexec.NewExecutable("composer")
+	versionExec := pexec.NewExecutable("composer")
 
 	packit.Run(
 		composer.Detect(logEmitter, phpVersionResolver),
@@ -37,9 +41,13 @@ func main() {
 			installExec,
 			globalExec,
 			checkPlatformReqsExec,
+			auditExec,
+			runScriptExec,
+			versionExec,
 			Generator{},
 			os.Getenv("PATH"),
 			fs.NewChecksumCalculator(),
+			servicebindings.NewResolver(),
 			chronos.DefaultClock),
 	)
 }
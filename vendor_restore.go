@@ -0,0 +1,117 @@
+package composer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	pfs "github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+const (
+	// VendorRestoreCopy copies the vendor directory.
+	VendorRestoreCopy = "copy"
+
+	// VendorRestoreHardlink hardlinks every file of the vendor directory, and falls back
+	// to copying when the source and destination are on different file systems. The
+	// workspace and the layer then share their files, so editing a file in place in one
+	// of them also edits it in the other.
+	VendorRestoreHardlink = "hardlink"
+)
+
+// vendorRestoreStrategy returns the strategy selected with BP_COMPOSER_VENDOR_RESTORE,
+// defaulting to VendorRestoreCopy.
+func vendorRestoreStrategy() (string, error) {
+	strategy, found := os.LookupEnv(BpComposerVendorRestore)
+	if !found || strategy == "" {
+		return VendorRestoreCopy, nil
+	}
+
+	switch strategy {
+	case VendorRestoreCopy, VendorRestoreHardlink:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("invalid value for %s: '%s', must be one of '%s' or '%s'",
+			BpComposerVendorRestore, strategy, VendorRestoreCopy, VendorRestoreHardlink)
+	}
+}
+
+// restoreVendorDir places the contents of the source vendor directory at destination
+// using the given strategy. Any existing destination is expected to have been removed.
+//
+// Hardlinking falls back to a copy when linking fails, for example because /workspace
+// and /layers are separate volumes.
+func restoreVendorDir(logger scribe.Emitter, clock chronos.Clock, strategy, source, destination string) error {
+	duration, err := clock.Measure(func() error {
+		err := os.MkdirAll(filepath.Dir(destination), os.ModePerm)
+		if err != nil { // untested
+			return err
+		}
+
+		switch strategy {
+		case VendorRestoreHardlink:
+			logger.Process("Hardlinking from %s => to %s", source, destination)
+			err := hardlinkDir(source, destination)
+			if err == nil {
+				return nil
+			}
+
+			logger.Subprocess("Unable to hardlink, falling back to copying: %s", err)
+			if err := os.RemoveAll(destination); err != nil { // untested
+				return err
+			}
+
+			return pfs.Copy(source, destination)
+
+		default:
+			logger.Process("Copying from %s => to %s", source, destination)
+			return pfs.Copy(source, destination)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Debug.Subprocess("Restored vendor directory in %s", duration.Round(time.Millisecond))
+
+	return nil
+}
+
+// hardlinkDir recreates the directory tree of source at destination, hardlinking
+// regular files and recreating symlinks with their original targets.
+func hardlinkDir(source, destination string) error {
+	return filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil { // untested
+			return err
+		}
+		target := filepath.Join(destination, rel)
+
+		switch {
+		case entry.IsDir():
+			info, err := entry.Info()
+			if err != nil { // untested
+				return err
+			}
+			return os.MkdirAll(target, info.Mode().Perm())
+
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil { // untested
+				return err
+			}
+			return os.Symlink(link, target)
+
+		default:
+			return os.Link(path, target)
+		}
+	})
+}